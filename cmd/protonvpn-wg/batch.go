@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/internal/keystore"
+	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/fileutil"
+)
+
+// Batch result statuses
+const (
+	batchPending = "pending"
+	batchOK      = "ok"
+	batchFailed  = "failed"
+)
+
+// batchReport is the JSON report of a batch run. It is rewritten after every device, so an
+// interrupted run can be resumed.
+type batchReport struct {
+	Manifest  string        `json:"manifest"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Devices   []batchResult `json:"devices"`
+}
+
+// batchResult is the outcome for one device, identified by its output file
+type batchResult struct {
+	Output         string `json:"output"`
+	DeviceName     string `json:"device_name"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+	ErrorClass     string `json:"error_class,omitempty"`
+	Server         string `json:"server,omitempty"`
+	SerialNumber   string `json:"serial_number,omitempty"`
+	ExpirationTime int64  `json:"expiration_time,omitempty"`
+}
+
+// runBatch generates the configs of all devices in a manifest, authenticating and fetching the
+// server list once and generating up to -concurrency devices at the same time
+func runBatch(ctx context.Context, args []string) error {
+	cfg, devices, err := config.ParseBatch(args)
+	if err != nil {
+		return usageError(err, config.PrintBatchUsage)
+	}
+
+	report := &batchReport{Manifest: cfg.BatchManifest}
+	for _, device := range devices {
+		report.Devices = append(report.Devices, batchResult{Output: device.OutputFile, DeviceName: device.DeviceName, Status: batchPending})
+	}
+	skipped := 0
+	if cfg.Resume {
+		if skipped, err = resumeBatch(cfg.ReportFile, report); err != nil {
+			return err
+		}
+	}
+	if skipped == len(devices) {
+		fmt.Printf("All %d devices were already generated (see %s)\n", len(devices), cfg.ReportFile)
+		return nil
+	}
+
+	store, err := openKeyStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	slog.Info("Authentication successful")
+
+	servers, err := vpn.NewClient(cfg, authClient.API()).GetServers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get servers: %w", err)
+	}
+
+	fmt.Printf("Generating %d devices (%d at a time)\n", len(devices)-skipped, cfg.Concurrency)
+	failed := generateBatch(ctx, cfg, devices, report, authClient.API(), store, servers)
+
+	fmt.Printf("\nGenerated %d, failed %d, skipped %d. Report: %s\n", len(devices)-skipped-failed, failed, skipped, cfg.ReportFile)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d devices failed (rerun with -resume to retry them)", failed, len(devices))
+	}
+	return nil
+}
+
+// generateBatch generates the pending devices of the report and returns the number that failed
+func generateBatch(ctx context.Context, cfg *config.Config, devices []*config.Config, report *batchReport, apiClient *api.Client, store *keystore.Store, servers []api.LogicalServer) int {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	record := func(i int, result batchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Devices[i] = result
+		if result.Status == batchOK {
+			fmt.Printf("ok      %s (%s, %s)\n", result.Output, result.DeviceName, result.Server)
+		} else {
+			failed++
+			fmt.Printf("failed  %s: %s\n", result.Output, result.Error)
+		}
+		if err := writeBatchReport(cfg.ReportFile, report); err != nil {
+			slog.Warn("Failed to write batch report", "error", err)
+		}
+	}
+
+	selected := prepareBatch(cfg, devices, report, servers, record)
+	slots := make(chan struct{}, cfg.Concurrency)
+
+	for i, device := range devices {
+		if selected[i] == nil {
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			record(i, generateBatchDevice(ctx, device, apiClient, store, selected[i]))
+		}()
+	}
+
+	wg.Wait()
+	return failed
+}
+
+// prepareBatch selects the server and expands the device name of every pending device before
+// any certificate is requested, so the workers don't race on names: a device whose name another
+// device of the batch already has fails, and suffixed names skip the names of the batch. It
+// returns the selected servers, nil for devices that are done or failed.
+func prepareBatch(cfg *config.Config, devices []*config.Config, report *batchReport, servers []api.LogicalServer, record func(int, batchResult)) []*api.LogicalServer {
+	selected := make([]*api.LogicalServer, len(devices))
+	names := make(map[string]int)
+	for i, device := range devices {
+		if report.Devices[i].Status == batchOK {
+			continue
+		}
+
+		// The username may have been prompted for during authentication
+		device.Username = cfg.Username
+		result := batchResult{Output: device.OutputFile, DeviceName: device.DeviceName}
+		server, err := vpn.NewServerSelector(device).SelectBest(servers)
+		if err == nil {
+			err = expandDeviceName(device, server)
+		}
+		if err == nil {
+			if previous, ok := names[device.DeviceName]; ok {
+				err = fmt.Errorf("device name %s is also used by device %d of the batch", device.DeviceName, previous)
+			}
+		}
+		if err != nil {
+			record(i, batchFailure(result, err))
+			continue
+		}
+
+		names[device.DeviceName] = i + 1
+		selected[i] = server
+	}
+
+	for i, device := range devices {
+		if selected[i] == nil {
+			continue
+		}
+		device.ReservedDeviceNames = make(map[string]bool, len(names))
+		for name := range names {
+			device.ReservedDeviceNames[name] = name != device.DeviceName
+		}
+	}
+	return selected
+}
+
+// generateBatchDevice generates the config of one device for its selected server
+func generateBatchDevice(ctx context.Context, device *config.Config, apiClient *api.Client, store *keystore.Store, server *api.LogicalServer) batchResult {
+	result := batchResult{Output: device.OutputFile, DeviceName: device.DeviceName}
+
+	out := io.Discard
+	if device.Debug {
+		out = os.Stdout
+	}
+
+	generated, err := writeConfig(ctx, device, vpn.NewClient(device, apiClient), store, nil, server, out)
+	if err != nil {
+		return batchFailure(result, err)
+	}
+
+	result.Status = batchOK
+	result.DeviceName = generated.certificate.DeviceName
+	result.Server = generated.server.Name
+	result.SerialNumber = generated.certificate.SerialNumber
+	result.ExpirationTime = generated.certificate.ExpirationTime
+	return result
+}
+
+// batchFailure marks a result as failed with err
+func batchFailure(result batchResult, err error) batchResult {
+	result.Status = batchFailed
+	result.Error = err.Error()
+	result.ErrorClass = string(errclass.Of(err))
+	return result
+}
+
+// resumeBatch marks the devices that a previous report lists as successful and returns how many
+func resumeBatch(path string, report *batchReport) (int, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // reading the user-specified report is intended
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("No report to resume, generating all devices", "report", path)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var previous batchReport
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return 0, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+
+	done := make(map[string]batchResult)
+	for _, result := range previous.Devices {
+		if result.Status == batchOK {
+			done[result.Output] = result
+		}
+	}
+
+	skipped := 0
+	for i := range report.Devices {
+		if result, ok := done[report.Devices[i].Output]; ok {
+			report.Devices[i] = result
+			skipped++
+		}
+	}
+	return skipped, nil
+}
+
+// writeBatchReport writes the report atomically
+func writeBatchReport(path string, report *batchReport) error {
+	report.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(path, append(data, '\n'), 0o644); err != nil { //nolint:gosec // the report holds no secrets
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
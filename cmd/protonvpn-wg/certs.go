@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/keystore"
+	"protonvpn-wg-config-generate/internal/prompt"
+	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/keys"
+	"protonvpn-wg-config-generate/pkg/timeutil"
+)
+
+// runCerts lists, revokes or updates the persistent certificates (devices) of the account
+func runCerts(ctx context.Context, args []string) error {
+	cfg, err := config.ParseCerts(args)
+	if err != nil {
+		return usageError(err, config.PrintCertsUsage)
+	}
+
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	vpnClient := vpn.NewClient(cfg, authClient.API())
+	certificates, err := vpnClient.ListCertificates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	if cfg.CertsAction == config.CertsActionList {
+		printCertificates(certificates)
+		return nil
+	}
+
+	store, err := openKeyStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.CertsAction == config.CertsActionUpdate {
+		return updateCertificates(ctx, cfg, authClient.API(), store, certificates)
+	}
+	return revokeCertificates(ctx, cfg, vpnClient, store, certificates)
+}
+
+// revokeCertificates revokes the certificates selected by the configured targets
+func revokeCertificates(ctx context.Context, cfg *config.Config, vpnClient *vpn.Client, store *keystore.Store, certificates []api.VPNInfo) error {
+	var olderThan time.Duration
+	if cfg.OlderThan != "" {
+		olderThan, _ = timeutil.ParseDuration(cfg.OlderThan)
+	}
+
+	matched := vpn.MatchCertificates(certificates, cfg.CertsTargets, olderThan, time.Now())
+	if olderThan > 0 {
+		if unknown := vpn.CountUnknownAge(certificates, cfg.CertsTargets); unknown > 0 {
+			fmt.Printf("Skipped %d device(s) with unknown age: -older-than only applies to auto-generated names (WireGuard-<user>-<unix>)\n", unknown)
+		}
+	}
+	if len(matched) == 0 {
+		fmt.Println("No certificates match")
+		return nil
+	}
+
+	printCertificates(matched)
+
+	if !cfg.AssumeYes {
+		confirmed, err := prompt.Confirm(ctx, fmt.Sprintf("\nRevoke %d certificate(s)?", len(matched)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+
+	var failed int
+	for i := range matched {
+		if err := vpnClient.RevokeCertificate(ctx, &matched[i]); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			slog.Warn("Failed to revoke certificate", "serial", matched[i].SerialNumber, "device", matched[i].DeviceName, "error", err)
+			failed++
+			continue
+		}
+		fmt.Printf("Revoked %s (%s)\n", matched[i].SerialNumber, matched[i].DeviceName)
+		forgetKey(store, &matched[i])
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to revoke %d of %d certificates", failed, len(matched))
+	}
+	return nil
+}
+
+// updateCertificates changes the features (or lifetime) of the certificates of stored keys by
+// requesting a new certificate for the same public key, so deployed configs stay valid
+func updateCertificates(ctx context.Context, cfg *config.Config, apiClient *api.Client, store *keystore.Store, certificates []api.VPNInfo) error {
+	if store == nil {
+		return fmt.Errorf("certs update needs the key store (remove -no-key-store)")
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	matched := matchStoredKeys(entries, cfg.CertsTargets)
+	if len(matched) == 0 {
+		return fmt.Errorf("no stored keys match %v (see keys list)", cfg.CertsTargets)
+	}
+
+	var failed int
+	for i := range matched {
+		if err := updateCertificate(ctx, cfg, apiClient, store, &matched[i], certificates); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			slog.Warn("Failed to update certificate", "device", matched[i].DeviceName, "error", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to update %d of %d certificates", failed, len(matched))
+	}
+	return nil
+}
+
+// updateCertificate re-requests the certificate of one stored key with the updated features
+func updateCertificate(ctx context.Context, cfg *config.Config, apiClient *api.Client, store *keystore.Store, entry *keystore.Entry, certificates []api.VPNInfo) error {
+	clientKey, err := keys.ParsePrivateKey(entry.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid stored key: %w", err)
+	}
+
+	// The account's certificate is authoritative; the key store may be out of date, and keys
+	// imported from a bare key or config have no features at all
+	current, err := findCertificate(certificates, entry, clientKey)
+	if err != nil {
+		return err
+	}
+	features, expiration := entry.Features, entry.ExpirationTime
+	if current != nil {
+		features, expiration = current.Features, current.ExpirationTime
+	} else if !allFeaturesGiven(cfg.Features) {
+		return fmt.Errorf("no current certificate found for the key, so its features are unknown (give all of -netshield, -accelerator, -moderate-nat and -port-forwarding)")
+	}
+	features = applyFeatureUpdate(features, cfg.Features)
+
+	deviceCfg := *cfg
+	deviceCfg.DeviceName = entry.DeviceName
+	deviceCfg.NetShieldLevel = features.NetshieldLevel
+	deviceCfg.EnableAccelerator = features.VPNAccelerator
+	deviceCfg.ModerateNAT = features.ModerateNAT
+	deviceCfg.PortForwarding = features.PortForwarding
+
+	// Keep the current expiry unless a new lifetime was given
+	if cfg.Duration == "" && cfg.ValidUntil == "" {
+		switch {
+		case expiration == 0:
+			deviceCfg.Duration = constants.DefaultCertDuration
+		case time.Unix(expiration, 0).Before(time.Now()):
+			return fmt.Errorf("certificate has expired (use renew, or give -duration)")
+		default:
+			deviceCfg.ValidUntil = time.Unix(expiration, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	vpnInfo, err := requestCertificate(ctx, &deviceCfg, vpn.NewClient(&deviceCfg, apiClient), store, clientKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s: %s\n", vpnInfo.DeviceName, vpnInfo.Features)
+	if vpnInfo.Features.PortForwarding {
+		fmt.Println("  Note: port forwarding only works if the config's server is a P2P server")
+	}
+	return nil
+}
+
+// matchStoredKeys returns the stored keys whose device name matches any of the targets (names or globs)
+func matchStoredKeys(entries []keystore.Entry, targets []string) []keystore.Entry {
+	var matched []keystore.Entry
+	for i := range entries {
+		for _, target := range targets {
+			if ok, err := path.Match(target, entries[i].DeviceName); err == nil && ok {
+				matched = append(matched, entries[i])
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// findCertificate returns the account's certificate for a stored key, if any. Keys without a
+// recorded fingerprint are matched by their public key.
+func findCertificate(certificates []api.VPNInfo, entry *keystore.Entry, clientKey *keys.KeyPair) (*api.VPNInfo, error) {
+	publicKey, err := clientKey.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored key: %w", err)
+	}
+
+	for i := range certificates {
+		if entry.Fingerprint != "" && certificates[i].ClientKeyFingerprint == entry.Fingerprint {
+			return &certificates[i], nil
+		}
+		if certificateKey, err := keys.ParsePublicKey(certificates[i].ClientKey); err == nil && certificateKey.Equal(publicKey) {
+			return &certificates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// allFeaturesGiven reports whether an update sets every feature, so it doesn't depend on the
+// current certificate
+func allFeaturesGiven(update config.FeatureUpdate) bool {
+	return update.NetShieldLevel != nil && update.VPNAccelerator != nil && update.ModerateNAT != nil && update.PortForwarding != nil
+}
+
+// applyFeatureUpdate returns the features with the given updates applied
+func applyFeatureUpdate(features api.CertificateFeatures, update config.FeatureUpdate) api.CertificateFeatures {
+	if update.NetShieldLevel != nil {
+		features.NetshieldLevel = *update.NetShieldLevel
+	}
+	if update.VPNAccelerator != nil {
+		features.VPNAccelerator = *update.VPNAccelerator
+	}
+	if update.ModerateNAT != nil {
+		features.ModerateNAT = *update.ModerateNAT
+	}
+	if update.PortForwarding != nil {
+		features.PortForwarding = *update.PortForwarding
+	}
+	return features
+}
+
+// forgetKey removes the stored key of a revoked certificate. Keys stored for the same device name
+// but a different key (e.g. after the name was reused) are kept.
+func forgetKey(store *keystore.Store, certificate *api.VPNInfo) {
+	if store == nil {
+		return
+	}
+
+	entry, err := store.Get(certificate.DeviceName)
+	if err != nil {
+		return
+	}
+	if entry.Fingerprint != "" && entry.Fingerprint != certificate.ClientKeyFingerprint {
+		return
+	}
+
+	if err := store.Delete(certificate.DeviceName); err != nil {
+		slog.Warn("Failed to delete stored key", "device", certificate.DeviceName, "error", err)
+	}
+}
+
+// printCertificates prints certificates as a table
+func printCertificates(certificates []api.VPNInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SERIAL\tDEVICE\tEXPIRES\tFEATURES")
+	for i := range certificates {
+		expires := "-"
+		if certificates[i].ExpirationTime > 0 {
+			expires = time.Unix(certificates[i].ExpirationTime, 0).Format("2006-01-02 15:04")
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			certificates[i].SerialNumber,
+			certificates[i].DeviceName,
+			expires,
+			certificates[i].Features)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/errclass"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+	usage   func()
+}
+
+// commands lists the subcommands in the order the usage shows them. It is set by init, since
+// the completion scripts are generated from it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"generate", "Generate a WireGuard configuration for the best server (default)", runGenerate, config.PrintUsage},
+		{"renew", "Renew the certificate of an existing configuration", runRenew, config.PrintRenewUsage},
+		{"batch", "Generate configurations for all devices of a manifest", runBatch, config.PrintBatchUsage},
+		{"servers", "List the servers generate selects from", runServers, config.PrintServersUsage},
+		{"certs", "List, revoke or update the account's certificates", runCerts, config.PrintCertsUsage},
+		{"keys", "List, export or import stored keys", withoutContext(runKeys), config.PrintKeysUsage},
+		{"status", "Report certificate expiry of configs or stored keys", withoutContext(runStatus), config.PrintStatusUsage},
+		{"port-forward", "Request and renew a forwarded port", runPortForward, config.PrintPortForwardUsage},
+		{"login", "Authenticate and save the session", runLogin, config.PrintLoginUsage},
+		{"logout", "Revoke and delete the saved session", runLogout, config.PrintLogoutUsage},
+		{"session", "Show the saved session", withoutContext(runSession), config.PrintSessionUsage},
+		{"doctor", "Check the session, API access and clock for common problems", runDoctor, config.PrintDoctorUsage},
+		{"config", "Show the effective options from the config file, profile and flags", withoutContext(runConfig), config.PrintConfigShowUsage},
+		{"completion", "Print a shell completion script", withoutContext(runCompletion), config.PrintCompletionUsage},
+	}
+}
+
+// withoutContext adapts a command that doesn't use the API
+func withoutContext(run func(args []string) error) func(context.Context, []string) error {
+	return func(_ context.Context, args []string) error {
+		return run(args)
+	}
+}
+
+// findCommand returns the command with the given name, or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// dispatch runs the command named by the first argument. Arguments starting with a flag run
+// generate, which keeps the original flag-only invocation working.
+func dispatch(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		printCommands()
+		return nil
+	}
+
+	name := args[0]
+	switch {
+	case strings.HasPrefix(name, "-"):
+		return runGenerate(ctx, args)
+	case name == "help":
+		return runHelp(args[1:])
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		printCommands()
+		return errclass.New(errclass.InvalidInput, "unknown command: %s", name)
+	}
+	return cmd.run(ctx, args[1:])
+}
+
+// usageError prints the usage of a command whose arguments failed to parse. Asking for help
+// with -h is not an error.
+func usageError(err error, usage func()) error {
+	usage()
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return errclass.Wrap(errclass.InvalidInput, err)
+}
+
+// runHelp prints the overview, or the usage of one command
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		printCommands()
+		return nil
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return errclass.New(errclass.InvalidInput, "unknown command: %s", args[0])
+	}
+	cmd.usage()
+	return nil
+}
+
+// printCommands prints the overview of all commands
+func printCommands() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s -username <username> -countries <country-codes> [options]  (same as generate)\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for i := range commands {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", commands[i].name, commands[i].summary)
+	}
+	_ = w.Flush()
+
+	fmt.Fprintf(os.Stderr, "\nRun '%s help <command>' for the options of a command.\n", os.Args[0])
+}
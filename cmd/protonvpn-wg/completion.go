@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/vpn"
+)
+
+// completionCommand is a command as the completion scripts see it
+type completionCommand struct {
+	Name    string
+	Summary string
+	Actions []string
+	Flags   []config.Flag
+}
+
+// completionScript is the data of a completion script template
+type completionScript struct {
+	Program  string
+	Func     string
+	Commands []completionCommand
+}
+
+// commandActions are the words completed as the first argument of a command
+var commandActions = map[string][]string{
+	"certs":      {config.CertsActionList, config.CertsActionRevoke, config.CertsActionUpdate},
+	"keys":       {config.KeysActionList, config.KeysActionExport, config.KeysActionImport},
+	"completion": {config.ShellBash, config.ShellZsh, config.ShellFish},
+}
+
+// runCompletion prints a completion script, or the values the scripts complete flags with
+func runCompletion(args []string) error {
+	cfg, err := config.ParseCompletion(args)
+	if err != nil {
+		return usageError(err, config.PrintCompletionUsage)
+	}
+
+	if cfg.CompletionValues != "" {
+		// Without a cache there is nothing to suggest, which the shell handles like no match
+		servers, err := vpn.LoadServerCache()
+		if err != nil {
+			return nil
+		}
+		for _, value := range completionValues(servers, cfg.CompletionValues, cfg.CompletionWord) {
+			fmt.Println(value)
+		}
+		return nil
+	}
+
+	program := filepath.Base(os.Args[0])
+	script := completionScript{
+		Program: program,
+		Func:    "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(program, "_"),
+	}
+	for i := range commands {
+		script.Commands = append(script.Commands, completionCommand{
+			Name:    commands[i].name,
+			Summary: commands[i].summary,
+			Actions: commandActions[commands[i].name],
+			Flags:   config.CommandFlags(commands[i].name),
+		})
+	}
+	help := completionCommand{Name: "help", Summary: "Show the options of a command"}
+	for i := range commands {
+		help.Actions = append(help.Actions, commands[i].name)
+	}
+	script.Commands = append(script.Commands, help)
+
+	return completionTemplates.ExecuteTemplate(os.Stdout, cfg.CompletionShell, script)
+}
+
+// completionValues completes word, a comma-separated list whose last item is being typed, with
+// the countries, cities or server names of the cached servers. Items already in the list are
+// left out and matching ignores case.
+func completionValues(servers []vpn.CachedServer, kind, word string) []string {
+	word = strings.NewReplacer(`\`, "", `"`, "", `'`, "").Replace(word)
+	head, last := "", word
+	if i := strings.LastIndex(word, ","); i >= 0 {
+		head, last = word[:i+1], word[i+1:]
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(head, ",") {
+		seen[strings.ToLower(item)] = true
+	}
+
+	var values []string
+	for _, server := range servers {
+		var value string
+		switch kind {
+		case config.ValuesCountries:
+			value = server.ExitCountry
+		case config.ValuesCities:
+			value = server.City
+		case config.ValuesServers:
+			value = server.Name
+		}
+
+		lower := strings.ToLower(value)
+		if seen[lower] || !strings.HasPrefix(lower, strings.ToLower(last)) {
+			continue
+		}
+		seen[lower] = true
+		values = append(values, head+value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// completionTemplates are the completion scripts, named by shell
+var completionTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"flags": func(flags []config.Flag) string {
+		names := make([]string, len(flags))
+		for i, f := range flags {
+			names[i] = "-" + f.Name
+		}
+		return strings.Join(names, " ")
+	},
+	"join": strings.Join,
+	"quote": func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	},
+	"dynamic": func(name string) bool {
+		return name == config.ValuesCountries || name == config.ValuesCities || name == config.ValuesServers
+	},
+}).Parse(`
+{{- define "bash" -}}
+# bash completion for {{.Program}}
+# Load with: source <({{.Program}} completion bash)
+
+{{.Func}}_values() {
+    local IFS=$'\n'
+    COMPREPLY=($("{{.Program}}" completion values "$1" "$2" 2>/dev/null))
+    COMPREPLY=("${COMPREPLY[@]// /\\ }")
+}
+
+{{.Func}}() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" command=generate words=""
+    if [[ ${COMP_CWORD} -gt 1 && ${COMP_WORDS[1]} != -* ]]; then
+        command="${COMP_WORDS[1]}"
+    fi
+
+    case "${prev}" in
+    -countries|-cities|-servers)
+        {{.Func}}_values "${prev#-}" "${cur}"
+        return
+        ;;
+    esac
+
+    if [[ ${cur} != -* && ${COMP_CWORD} -eq 1 ]]; then
+        words="{{range $i, $c := .Commands}}{{if $i}} {{end}}{{$c.Name}}{{end}}"
+    elif [[ ${cur} != -* && ${COMP_CWORD} -eq 2 ]]; then
+        case "${command}" in
+{{- range .Commands}}{{if .Actions}}
+        {{.Name}}) words="{{join .Actions " "}}" ;;
+{{- end}}{{end}}
+        esac
+    elif [[ ${cur} == -* ]]; then
+        case "${command}" in
+{{- range .Commands}}{{if .Flags}}
+        {{.Name}}) words="{{flags .Flags}}" ;;
+{{- end}}{{end}}
+        esac
+    fi
+    COMPREPLY=($(compgen -W "${words}" -- "${cur}"))
+}
+
+complete -o default -F {{.Func}} {{.Program}}
+{{end}}
+
+{{- define "zsh" -}}
+#compdef {{.Program}}
+# zsh completion for {{.Program}}
+# Load with: source <({{.Program}} completion zsh), or save as {{.Func}} in a directory of $fpath
+
+{{.Func}}() {
+    local cur=${words[CURRENT]} prev=${words[CURRENT-1]} command=generate
+    if (( CURRENT > 2 )) && [[ ${words[2]} != -* ]]; then
+        command=${words[2]}
+    fi
+
+    case $prev in
+    -countries|-cities|-servers)
+        local -a values
+        values=(${(f)"$({{.Program}} completion values ${prev#-} $cur 2>/dev/null)"})
+        compadd -U -- $values
+        return
+        ;;
+    esac
+
+    if [[ $cur != -* ]] && (( CURRENT == 2 )); then
+        compadd -- {{range .Commands}} {{.Name}}{{end}}
+    elif [[ $cur != -* ]] && (( CURRENT == 3 )); then
+        case $command in
+{{- range .Commands}}{{if .Actions}}
+        {{.Name}}) compadd -- {{join .Actions " "}} ;;
+{{- end}}{{end}}
+        *) _files ;;
+        esac
+    elif [[ $cur == -* ]]; then
+        case $command in
+{{- range .Commands}}{{if .Flags}}
+        {{.Name}}) compadd -- {{flags .Flags}} ;;
+{{- end}}{{end}}
+        esac
+    else
+        _files
+    fi
+}
+
+if [[ $funcstack[1] == {{.Func}} ]]; then
+    {{.Func}} "$@"
+else
+    compdef {{.Func}} {{.Program}}
+fi
+{{end}}
+
+{{- define "fish" -}}
+# fish completion for {{.Program}}
+# Load with: {{.Program}} completion fish | source
+
+function {{.Func}}_command
+    set -l words (commandline -opc)
+    if test (count $words) -gt 1; and not string match -q -- '-*' $words[2]
+        echo $words[2]
+    else
+        echo generate
+    end
+end
+
+function {{.Func}}_using
+    test ({{.Func}}_command) = $argv[1]
+end
+
+function {{.Func}}_action
+    {{.Func}}_using $argv[1]; and test (count (commandline -opc)) -eq 2
+end
+
+function {{.Func}}_values
+    {{.Program}} completion values $argv[1] (commandline -ct) 2>/dev/null
+end
+
+{{range .Commands -}}
+complete -c {{$.Program}} -n __fish_use_subcommand -f -a {{.Name}} -d {{quote .Summary}}
+{{end}}
+{{- range $c := .Commands}}{{if .Actions}}
+complete -c {{$.Program}} -n '{{$.Func}}_action {{$c.Name}}' -f -a {{quote (join $c.Actions " ")}}
+{{- end}}{{end}}
+{{range $c := .Commands}}{{range .Flags}}
+{{- if dynamic .Name}}
+complete -c {{$.Program}} -n '{{$.Func}}_using {{$c.Name}}' -o {{.Name}} -x -a '({{$.Func}}_values {{.Name}})' -d {{quote .Usage}}
+{{- else if .Bool}}
+complete -c {{$.Program}} -n '{{$.Func}}_using {{$c.Name}}' -o {{.Name}} -d {{quote .Usage}}
+{{- else}}
+complete -c {{$.Program}} -n '{{$.Func}}_using {{$c.Name}}' -o {{.Name}} -r -d {{quote .Usage}}
+{{- end}}{{end}}{{end}}
+{{end}}
+`))
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"protonvpn-wg-config-generate/internal/config"
+)
+
+// runConfig prints the effective generate options after merging the config file, profile and flags
+func runConfig(args []string) error {
+	cfg, settings, err := config.ParseConfigShow(args)
+	if err != nil {
+		return usageError(err, config.PrintConfigShowUsage)
+	}
+
+	path := cfg.ConfigFile
+	if path == "" {
+		path = config.DefaultConfigFilePath()
+	}
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("Config file: %s (not found)\n", path)
+	} else {
+		fmt.Printf("Config file: %s\n", path)
+	}
+	if cfg.Profile != "" {
+		fmt.Printf("Profile: %s\n", cfg.Profile)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "OPTION\tVALUE\tSOURCE")
+	for _, setting := range settings {
+		value := setting.Value
+		if value == "" {
+			value = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Name, value, setting.Source)
+	}
+	return w.Flush()
+}
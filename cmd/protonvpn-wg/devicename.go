@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/keystore"
+	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/keys"
+)
+
+// expandDeviceName expands a device name template with the selected server
+func expandDeviceName(cfg *config.Config, server *api.LogicalServer) error {
+	if !vpn.IsDeviceNameTemplate(cfg.DeviceName) {
+		return nil
+	}
+
+	deviceName, err := vpn.ExpandDeviceName(cfg.DeviceName, vpn.NewDeviceNameData(server, cfg.Username, time.Now()))
+	if err != nil {
+		return err
+	}
+	cfg.DeviceName = deviceName
+	return nil
+}
+
+// checkDeviceName looks for certificates of other keys that already use the device name and
+// handles them as configured: refusing, switching to a free suffixed name, or returning them to
+// be revoked once the new certificate is issued
+func checkDeviceName(ctx context.Context, cfg *config.Config, vpnClient *vpn.Client, clientKey *keys.KeyPair) ([]api.VPNInfo, error) {
+	// Auto-generated names are unique
+	if cfg.DeviceName == "" {
+		return nil, nil
+	}
+
+	publicKey, err := clientKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	certificates, err := vpnClient.ListCertificates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	collisions := vpn.DeviceNameCollisions(certificates, cfg.DeviceName, publicKey)
+	if len(collisions) == 0 {
+		return nil, nil
+	}
+
+	switch cfg.OnCollision {
+	case config.CollisionRefuse:
+		return nil, fmt.Errorf("device name %s is already used by certificate %s (see -on-collision)", cfg.DeviceName, collisions[0].SerialNumber)
+	case config.CollisionRevoke:
+		slog.Info("Device name already used, the other certificates will be revoked", "device", cfg.DeviceName, "certificates", len(collisions))
+		return collisions, nil
+	default:
+		deviceName := vpn.UniqueDeviceName(certificates, cfg.DeviceName, cfg.ReservedDeviceNames)
+		slog.Info("Device name already used, adding a suffix", "device", cfg.DeviceName, "new_name", deviceName)
+		cfg.DeviceName = deviceName
+		return nil, nil
+	}
+}
+
+// revokeCollisions revokes the certificates that used the device name before
+func revokeCollisions(ctx context.Context, vpnClient *vpn.Client, store *keystore.Store, collisions []api.VPNInfo) error {
+	for i := range collisions {
+		if err := vpnClient.RevokeCertificate(ctx, &collisions[i]); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return err
+			}
+			slog.Warn("Failed to revoke certificate", "serial", collisions[i].SerialNumber, "device", collisions[i].DeviceName, "error", err)
+			continue
+		}
+		fmt.Printf("Revoked %s (%s)\n", collisions[i].SerialNumber, collisions[i].DeviceName)
+		forgetKey(store, &collisions[i])
+	}
+	return nil
+}
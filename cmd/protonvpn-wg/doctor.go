@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/doctor"
+)
+
+// runDoctor checks the saved session, API access and the clock and prints a report with hints.
+// It fails if any check failed; warnings don't fail it.
+func runDoctor(ctx context.Context, args []string) error {
+	cfg, err := config.ParseDoctor(args)
+	if err != nil {
+		return usageError(err, config.PrintDoctorUsage)
+	}
+
+	report := doctor.Run(ctx, cfg)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if cfg.DoctorFormat == config.DoctorFormatJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printDoctorReport(report)
+	}
+
+	failed := 0
+	for _, check := range report.Checks {
+		if check.Status == doctor.Fail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
+
+// printDoctorReport prints one line per check, followed by its hint
+func printDoctorReport(report *doctor.Report) {
+	width := 0
+	for _, check := range report.Checks {
+		width = max(width, len(check.Title))
+	}
+
+	for _, check := range report.Checks {
+		fmt.Printf("%-4s  %-*s  %s\n", strings.ToUpper(string(check.Status)), width, check.Title, check.Message)
+		if check.Hint != "" {
+			fmt.Printf("%-4s  %-*s  Hint: %s\n", "", width, "", check.Hint)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/wireguard"
+)
+
+// dryRun authenticates and selects a server like generate, then prints the configuration it
+// would write. No key is generated, no certificate is requested and the key store and output
+// file are left alone.
+func dryRun(ctx context.Context, cfg *config.Config) error {
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	slog.Info("Authentication successful")
+
+	servers, err := vpn.NewClient(cfg, authClient.API()).GetServers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get servers: %w", err)
+	}
+
+	server, err := selectServer(ctx, cfg, servers)
+	if err != nil {
+		return err
+	}
+	printSelectedServer(os.Stdout, server)
+
+	physicalServer := vpn.GetBestPhysicalServer(server)
+	if physicalServer == nil {
+		return errclass.New(errclass.NoServers, "no physical servers available")
+	}
+	if err := vpn.ValidateServerFeatures(cfg, server.Features); err != nil {
+		return err
+	}
+	if err := expandDeviceName(cfg, server); err != nil {
+		return err
+	}
+
+	// Configs for an external public key get the usual placeholder
+	privateKey := wireguard.DryRunPrivateKeyPlaceholder
+	if cfg.PublicKey != "" {
+		privateKey = ""
+	}
+	content, err := wireguard.NewConfigGenerator(cfg).Render(server, physicalServer, privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to generate WireGuard config: %w", err)
+	}
+
+	deviceName := cfg.DeviceName
+	if deviceName == "" {
+		deviceName = "(auto-generated)"
+	}
+	lifetime := cfg.Duration
+	if cfg.ValidUntil != "" {
+		lifetime = "until " + cfg.ValidUntil
+	}
+	action := "create"
+	if _, err := os.Stat(cfg.OutputFile); err == nil {
+		action = "replace"
+	}
+
+	fmt.Println("\nDry run: no certificate was requested and nothing was written.")
+	fmt.Printf("Would request a certificate for device %s (lifetime %s, features %s)\n", deviceName, lifetime, vpn.RequestedFeatures(cfg))
+	fmt.Printf("Would %s %s with:\n\n%s", action, cfg.OutputFile, content)
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/keystore"
+	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/keys"
+	"protonvpn-wg-config-generate/pkg/wireguard"
+)
+
+// runKeys lists, exports or imports the keys in the key store
+func runKeys(args []string) error {
+	cfg, err := config.ParseKeys(args)
+	if err != nil {
+		return usageError(err, config.PrintKeysUsage)
+	}
+
+	store, err := openKeyStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch cfg.KeysAction {
+	case config.KeysActionExport:
+		return exportKey(cfg, store)
+	case config.KeysActionImport:
+		return importKey(cfg, store)
+	default:
+		return listKeys(store)
+	}
+}
+
+// listKeys prints the stored keys as a table
+func listKeys(store *keystore.Store) error {
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No keys stored in %s\n", store.Dir())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "DEVICE\tSERIAL\tEXPIRES\tFEATURES")
+	for i := range entries {
+		// Imported keys have no certificate metadata until they are renewed
+		serial, expires, features := "-", "-", "-"
+		if entries[i].SerialNumber != "" {
+			serial = entries[i].SerialNumber
+			expires = time.Unix(entries[i].ExpirationTime, 0).Format("2006-01-02 15:04")
+			features = entries[i].Features.String()
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entries[i].DeviceName, serial, expires, features)
+	}
+	return w.Flush()
+}
+
+// exportKey writes a stored key to stdout
+func exportKey(cfg *config.Config, store *keystore.Store) error {
+	entry, err := store.Get(cfg.KeysTargets[0])
+	if err != nil {
+		return err
+	}
+
+	if cfg.KeyFormat == config.KeyFormatKey {
+		fmt.Println(entry.PrivateKey)
+		return nil
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// importKey stores a key read from a JSON export, a bare key or a WireGuard config
+func importKey(cfg *config.Config, store *keystore.Store) error {
+	var data []byte
+	var err error
+	if cfg.KeysTargets[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(cfg.KeysTargets[0]) //nolint:gosec // reading the user-specified file is intended
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+
+	entry := &keystore.Entry{}
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, "{") {
+		if err := json.Unmarshal(data, entry); err != nil {
+			return fmt.Errorf("failed to parse key export: %w", err)
+		}
+	} else {
+		existing, err := wireguard.ParseConfig(content)
+		switch {
+		case err == nil && existing.PeerOnly:
+			return fmt.Errorf("%s is a peer-only config, which has no private key", cfg.KeysTargets[0])
+		case err == nil:
+			entry.PrivateKey, entry.DeviceName = existing.PrivateKey, existing.DeviceName
+		default:
+			if entry.PrivateKey, err = wireguard.ParseKey(content); err != nil {
+				return err
+			}
+		}
+	}
+
+	if cfg.DeviceName != "" {
+		entry.DeviceName = cfg.DeviceName
+	}
+	if entry.DeviceName == "" {
+		return fmt.Errorf("no device name in %s (use -device-name)", cfg.KeysTargets[0])
+	}
+	if _, err := keys.ParsePrivateKey(entry.PrivateKey); err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+
+	if err := store.Put(entry); err != nil {
+		return err
+	}
+	fmt.Printf("Imported key for device %s into %s\n", entry.DeviceName, store.Dir())
+	return nil
+}
+
+// openKeyStore opens the configured key store, or returns nil if it is disabled
+func openKeyStore(cfg *config.Config) (*keystore.Store, error) {
+	if cfg.NoKeyStore {
+		return nil, nil
+	}
+
+	dir := cfg.KeyStoreDir
+	if dir == "" {
+		dir = keystore.DefaultDir()
+	}
+	return keystore.Open(dir)
+}
+
+// resolveClientKey returns the externally generated public key given with -public-key, the
+// stored key of an existing device, or a newly generated key pair. It returns nil while the
+// device name is a template, since the stored key can only be looked up once it is expanded.
+func resolveClientKey(cfg *config.Config, store *keystore.Store) (*keys.KeyPair, error) {
+	if cfg.PublicKey != "" {
+		encoded := cfg.PublicKey
+		// The flag takes the key itself or a file containing it
+		if data, err := os.ReadFile(cfg.PublicKey); err == nil { //nolint:gosec // reading the user-specified file is intended
+			encoded = string(data)
+		}
+		publicKey, err := keys.ParsePublicKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -public-key: %w", err)
+		}
+		return keys.FromPublicKey(publicKey), nil
+	}
+
+	if vpn.IsDeviceNameTemplate(cfg.DeviceName) {
+		return nil, nil
+	}
+
+	// Reuse the stored key of an existing device, otherwise generate a key pair
+	clientKey, err := lookupKey(store, cfg.DeviceName)
+	if err != nil {
+		return nil, err
+	}
+	if clientKey != nil {
+		slog.Info("Using stored key", "device", cfg.DeviceName)
+		return clientKey, nil
+	}
+	return keys.Generate()
+}
+
+// lookupKey returns the stored key of a device, or nil if there is none
+func lookupKey(store *keystore.Store, deviceName string) (*keys.KeyPair, error) {
+	if store == nil || deviceName == "" {
+		return nil, nil
+	}
+
+	entry, err := store.Get(deviceName)
+	if errors.Is(err, keystore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clientKey, err := keys.ParsePrivateKey(entry.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored key for device %s: %w", deviceName, err)
+	}
+	return clientKey, nil
+}
+
+// storeCertificate records the key and its new certificate in the key store
+func storeCertificate(store *keystore.Store, clientKey *keys.KeyPair, certificate *api.VPNInfo) {
+	// Keys generated on the device have no private key to store
+	if store == nil || certificate.DeviceName == "" || !clientKey.HasPrivateKey() {
+		return
+	}
+	if err := store.Put(keystore.NewEntry(clientKey.PrivateKey(), certificate)); err != nil {
+		slog.Warn("Failed to save key", "device", certificate.DeviceName, "error", err)
+	}
+}
@@ -2,103 +2,239 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"protonvpn-wg-config-generate/internal/api"
 	"protonvpn-wg-config-generate/internal/auth"
 	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/internal/keystore"
+	"protonvpn-wg-config-generate/internal/logging"
+	"protonvpn-wg-config-generate/internal/picker"
 	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/keys"
+	"protonvpn-wg-config-generate/pkg/timeutil"
 	"protonvpn-wg-config-generate/pkg/wireguard"
-
-	"github.com/ProtonVPN/go-vpn-lib/ed25519"
 )
 
 func main() {
-	if err := run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restore default signal handling once interrupted, so a second Ctrl-C exits immediately
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Commands without logging flags log at the default level
+	_ = logging.Setup(os.Stderr, logging.LevelInfo, logging.FormatText)
+
+	if err := dispatch(ctx, os.Args[1:]); err != nil {
+		class := errclass.Of(err)
+		switch {
+		case logging.JSON():
+			slog.Error(err.Error(), "class", class, "exit_code", class.ExitCode())
+		case errors.Is(err, context.Canceled):
+			fmt.Fprintln(os.Stderr, "Interrupted")
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(class.ExitCode())
 	}
 }
 
-func run() error {
+// runGenerate generates a WireGuard configuration for the best matching server
+func runGenerate(ctx context.Context, args []string) error {
 	// Parse configuration
-	cfg, err := config.Parse()
+	cfg, err := config.Parse(args)
 	if err != nil {
-		config.PrintUsage()
-		return err
+		return usageError(err, config.PrintUsage)
 	}
 
-	// Authenticate
-	authClient := auth.NewClient(cfg)
-	session, err := authClient.Authenticate()
-	if err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+	// A dry run doesn't open the key store, which creates its directory
+	if cfg.DryRun {
+		return dryRun(ctx, cfg)
 	}
-	fmt.Println("Authentication successful!")
 
-	// Generate key pair
-	keyPair, err := ed25519.NewKeyPair()
+	store, err := openKeyStore(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to generate key pair: %w", err)
+		return err
 	}
-	cfg.ClientPrivateKey = keyPair.ToX25519Base64()
-
-	// Create VPN client
-	vpnClient := vpn.NewClient(cfg, session)
 
-	// Get VPN certificate
-	vpnInfo, err := vpnClient.GetCertificate(keyPair)
+	clientKey, err := resolveClientKey(cfg, store)
 	if err != nil {
-		return fmt.Errorf("failed to get VPN certificate: %w", err)
+		return err
 	}
 
+	// Authenticate
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	slog.Info("Authentication successful")
+
+	return generateConfig(ctx, cfg, vpn.NewClient(cfg, authClient.API()), store, clientKey)
+}
+
+// generateConfig requests a certificate for the client key and writes a configuration
+// for the best matching server. A nil client key is resolved once the device name is known.
+func generateConfig(ctx context.Context, cfg *config.Config, vpnClient *vpn.Client, store *keystore.Store, clientKey *keys.KeyPair) error {
 	// Get server list
-	servers, err := vpnClient.GetServers()
+	servers, err := vpnClient.GetServers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get servers: %w", err)
 	}
+	if !cfg.Interactive {
+		vpn.SaveServerCache(servers)
+	}
 
-	// Select best server
-	selector := vpn.NewServerSelector(cfg)
-	server, err := selector.SelectBest(servers)
+	server, err := selectServer(ctx, cfg, servers)
 	if err != nil {
 		return err
 	}
 
-	// Build feature list string
-	features := api.GetFeatureNames(server.Features)
-	featureStr := ""
-	if len(features) > 0 {
-		featureStr = fmt.Sprintf(", Features: %s", strings.Join(features, ", "))
+	result, err := writeConfig(ctx, cfg, vpnClient, store, clientKey, server, os.Stdout)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Selected server: %s (Country: %s, City: %s, Tier: %s, Load: %d%%, Score: %.2f, Servers: %d%s)\n",
-		server.Name, server.ExitCountry, server.City, api.GetTierName(server.Tier),
-		server.Load, server.Score, len(server.Servers), featureStr)
+	// Show final success
+	fmt.Printf("\nSuccessfully generated config for %s\n", result.server.ExitCountry)
+	return nil
+}
+
+// generated is the outcome of writing a configuration
+type generated struct {
+	server      *api.LogicalServer
+	certificate *api.VPNInfo
+}
+
+// selectServer selects the best matching server, or lets the user pick one with -interactive
+func selectServer(ctx context.Context, cfg *config.Config, servers []api.LogicalServer) (*api.LogicalServer, error) {
+	if cfg.Interactive {
+		return picker.Run(ctx, cfg, servers)
+	}
+	return vpn.NewServerSelector(cfg).SelectBest(servers)
+}
+
+// writeConfig requests a certificate for the client key and writes the configuration for the
+// server, reporting progress to out
+func writeConfig(ctx context.Context, cfg *config.Config, vpnClient *vpn.Client, store *keystore.Store, clientKey *keys.KeyPair, server *api.LogicalServer, out io.Writer) (*generated, error) {
+	printSelectedServer(out, server)
 
 	// Get best physical server
 	physicalServer := vpn.GetBestPhysicalServer(server)
 	if physicalServer == nil {
-		return fmt.Errorf("no physical servers available")
+		return nil, errclass.New(errclass.NoServers, "no physical servers available")
+	}
+
+	// Request the certificate only once the server is known to support its features
+	if err := vpn.ValidateServerFeatures(cfg, server.Features); err != nil {
+		return nil, err
+	}
+
+	// Expand a device name template, which may name a stored device, and check the name is free
+	err := expandDeviceName(cfg, server)
+	if err != nil {
+		return nil, err
+	}
+	if clientKey == nil {
+		if clientKey, err = resolveClientKey(cfg, store); err != nil {
+			return nil, err
+		}
+	}
+	cfg.ClientPrivateKey = clientKey.PrivateKey()
+	collisions, err := checkDeviceName(ctx, cfg, vpnClient, clientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	vpnInfo, err := requestCertificate(ctx, cfg, vpnClient, store, clientKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := revokeCollisions(ctx, vpnClient, store, collisions); err != nil {
+		return nil, err
+	}
+
+	// Don't write anything once interrupted
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	// Generate WireGuard configuration
 	generator := wireguard.NewConfigGenerator(cfg)
+	generator.SetCertificate(vpnInfo)
 	if err := generator.Generate(server, physicalServer, cfg.ClientPrivateKey); err != nil {
-		return fmt.Errorf("failed to generate WireGuard config: %w", err)
+		return nil, fmt.Errorf("failed to generate WireGuard config: %w", err)
 	}
 
-	fmt.Printf("WireGuard configuration written to: %s\n", cfg.OutputFile)
+	_, _ = fmt.Fprintf(out, "WireGuard configuration written to: %s\n", cfg.OutputFile)
+
+	// The device holding the private key has to match this public key
+	if !clientKey.HasPrivateKey() {
+		wgPublicKey, _ := clientKey.WireGuardPublicKey()
+		_, _ = fmt.Fprintf(out, "Client WireGuard public key: %s (the device's private key must match it)\n", wgPublicKey)
+	}
 
 	// Note about persistence
 	if vpnInfo.DeviceName != "" {
-		fmt.Printf("Device name: %s (visible in ProtonVPN dashboard)\n", vpnInfo.DeviceName)
+		_, _ = fmt.Fprintf(out, "Device name: %s (visible in ProtonVPN dashboard)\n", vpnInfo.DeviceName)
 	}
+	_, _ = fmt.Fprintf(out, "Certificate features: %s\n", vpnInfo.Features)
+	printCertificateExpiry(out, vpnInfo)
 
-	// Show final success
-	fmt.Printf("\nSuccessfully generated config for %s\n", server.ExitCountry)
+	return &generated{server: server, certificate: vpnInfo}, nil
+}
 
-	return nil
+// printSelectedServer prints a summary of the selected server
+func printSelectedServer(out io.Writer, server *api.LogicalServer) {
+	// Build feature list string
+	features := api.GetFeatureNames(server.Features)
+	featureStr := ""
+	if len(features) > 0 {
+		featureStr = fmt.Sprintf(", Features: %s", strings.Join(features, ", "))
+	}
+
+	_, _ = fmt.Fprintf(out, "Selected server: %s (Country: %s, City: %s, Tier: %s, Load: %d%%, Score: %.2f, Servers: %d%s)\n",
+		server.Name, server.ExitCountry, server.City, api.GetTierName(server.Tier),
+		server.Load, server.Score, len(server.Servers), featureStr)
+}
+
+// printCertificateExpiry prints when a certificate expires
+func printCertificateExpiry(out io.Writer, vpnInfo *api.VPNInfo) {
+	if vpnInfo.ExpirationTime > 0 {
+		expiresAt := time.Unix(vpnInfo.ExpirationTime, 0)
+		_, _ = fmt.Fprintf(out, "Certificate expires: %s (in %s)\n", expiresAt.Format("2006-01-02 15:04 MST"), timeutil.HumanizeDuration(time.Until(expiresAt)))
+	}
+}
+
+// requestCertificate requests a certificate for the client key, saves both in the key store and
+// records the device name, so that it ends up in the config header and is kept on renewal
+func requestCertificate(ctx context.Context, cfg *config.Config, vpnClient *vpn.Client, store *keystore.Store, clientKey *keys.KeyPair) (*api.VPNInfo, error) {
+	publicKeyPEM, err := clientKey.PublicKeyPEM()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key PEM: %w", err)
+	}
+
+	vpnInfo, err := vpnClient.GetCertificate(ctx, publicKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VPN certificate: %w", err)
+	}
+
+	if vpnInfo.DeviceName != "" {
+		cfg.DeviceName = vpnInfo.DeviceName
+	}
+	storeCertificate(store, clientKey, vpnInfo)
+	return vpnInfo, nil
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/pkg/fileutil"
+	"protonvpn-wg-config-generate/pkg/natpmp"
+)
+
+// runPortForward requests a forwarded port from the tunnel gateway and renews the mapping
+// before it expires, reporting the port whenever it changes
+func runPortForward(ctx context.Context, args []string) error {
+	cfg, err := config.ParsePortForward(args)
+	if err != nil {
+		return usageError(err, config.PrintPortForwardUsage)
+	}
+
+	client := natpmp.NewClient(cfg.Gateway)
+	var current uint16
+
+	for {
+		port, lifetime, err := mapPort(ctx, cfg, client, current)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil && cfg.Once:
+			return fmt.Errorf("port mapping failed: %w", err)
+		case err != nil:
+			slog.Warn("Port mapping failed, retrying", "delay", constants.PortMappingRetryDelay, "error", err)
+			lifetime = 2 * constants.PortMappingRetryDelay
+		case port != current:
+			current = port
+			if err := reportPort(ctx, cfg, port); err != nil {
+				return err
+			}
+		}
+
+		if cfg.Once {
+			return nil
+		}
+
+		// Renew at half the lifetime, as recommended by RFC 6886, but not so often that a short
+		// granted lifetime floods the gateway
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(max(lifetime/2, constants.PortMappingRetryDelay)):
+		}
+	}
+}
+
+// mapPort maps the configured protocols, asking for the current port to keep it stable across
+// renewals, and returns the assigned port and the shortest granted lifetime
+func mapPort(ctx context.Context, cfg *config.Config, client *natpmp.Client, current uint16) (uint16, time.Duration, error) {
+	var protocols []natpmp.Protocol
+	switch cfg.PortForwardProtocol {
+	case config.PortForwardUDP:
+		protocols = []natpmp.Protocol{natpmp.UDP}
+	case config.PortForwardTCP:
+		protocols = []natpmp.Protocol{natpmp.TCP}
+	default:
+		protocols = []natpmp.Protocol{natpmp.UDP, natpmp.TCP}
+	}
+
+	port := current
+	lifetime := cfg.PortMappingLifetime
+	for _, protocol := range protocols {
+		mapping, err := client.AddPortMapping(ctx, protocol, constants.NATPMPInternalPort, port, cfg.PortMappingLifetime)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s: %w", protocol, err)
+		}
+		// A lifetime of 0 means the mapping was destroyed (RFC 6886)
+		if mapping.Lifetime <= 0 {
+			return 0, 0, fmt.Errorf("%s: the gateway granted no lifetime", protocol)
+		}
+		if port != 0 && mapping.ExternalPort != port {
+			slog.Debug("Mapped to a different port", "protocol", protocol, "port", mapping.ExternalPort, "requested", port)
+		}
+		port = mapping.ExternalPort
+		lifetime = min(lifetime, mapping.Lifetime)
+	}
+
+	return port, lifetime, nil
+}
+
+// reportPort prints the forwarded port and passes it to the port file and hook, if configured
+func reportPort(ctx context.Context, cfg *config.Config, port uint16) error {
+	value := strconv.Itoa(int(port))
+	fmt.Printf("Forwarded port: %s (%s)\n", value, cfg.PortForwardProtocol)
+
+	if cfg.PortFile != "" {
+		if err := fileutil.WriteFileAtomic(cfg.PortFile, []byte(value+"\n"), 0o644); err != nil { //nolint:gosec // the port number is not sensitive
+			return fmt.Errorf("failed to write port file: %w", err)
+		}
+	}
+
+	if cfg.PortHook != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", cfg.PortHook, "sh", value) //nolint:gosec // the hook is a user-provided command by design
+		cmd.Env = append(os.Environ(), "PORT="+value)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			slog.Warn("Port hook failed", "error", err)
+		}
+	}
+
+	return nil
+}
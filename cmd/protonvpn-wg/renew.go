@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/internal/keystore"
+	"protonvpn-wg-config-generate/internal/vpn"
+	"protonvpn-wg-config-generate/pkg/keys"
+	"protonvpn-wg-config-generate/pkg/wireguard"
+)
+
+// runRenew requests a new certificate for the private key of an existing configuration, so
+// deployed peers keep working without distributing a new key
+func runRenew(ctx context.Context, args []string) error {
+	cfg, err := config.ParseRenew(args)
+	if err != nil {
+		return usageError(err, config.PrintRenewUsage)
+	}
+
+	existing, err := readExistingConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Keep the device name shown in the dashboard unless a new one is given
+	renamed := cfg.DeviceName != "" && (existing == nil || cfg.DeviceName != existing.DeviceName)
+	if cfg.DeviceName == "" && existing != nil {
+		cfg.DeviceName = existing.DeviceName
+	}
+
+	store, err := openKeyStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	clientKey, err := loadRenewKey(cfg, existing, store)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Reselect {
+		if existing == nil {
+			return fmt.Errorf("no configuration at %s to keep the endpoint from (use -reselect -countries to select a server)", cfg.OutputFile)
+		}
+		if cfg.PortForwarding && !existing.HasServerFeature("P2P") {
+			return fmt.Errorf("port forwarding requires a P2P server, which %s isn't for (use -reselect -countries to select one)", existing.Path)
+		}
+	}
+
+	// Authenticate
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	slog.Info("Authentication successful")
+
+	vpnClient := vpn.NewClient(cfg, authClient.API())
+	if cfg.Reselect {
+		return generateConfig(ctx, cfg, vpnClient, store, clientKey)
+	}
+
+	// A new name may already be used by another device
+	var collisions []api.VPNInfo
+	if renamed {
+		if collisions, err = checkDeviceName(ctx, cfg, vpnClient, clientKey); err != nil {
+			return err
+		}
+	}
+
+	vpnInfo, err := requestCertificate(ctx, cfg, vpnClient, store, clientKey)
+	if err != nil {
+		return err
+	}
+	if err := revokeCollisions(ctx, vpnClient, store, collisions); err != nil {
+		return err
+	}
+
+	// Don't write anything once interrupted
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := existing.Renew(vpnInfo, time.Now()); err != nil {
+		return fmt.Errorf("failed to update WireGuard config: %w", err)
+	}
+
+	fmt.Printf("Certificate renewed for device %s (%s)\n", vpnInfo.DeviceName, vpnInfo.Features)
+	printCertificateExpiry(os.Stdout, vpnInfo)
+	fmt.Printf("WireGuard configuration updated: %s (key and endpoint %s unchanged)\n", existing.Path, existing.Endpoint)
+
+	return nil
+}
+
+// readExistingConfig reads the configuration being renewed, or returns nil if it doesn't exist
+func readExistingConfig(cfg *config.Config) (*wireguard.ExistingConfig, error) {
+	existing, err := wireguard.ReadConfig(cfg.OutputFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return existing, err
+}
+
+// loadRenewKey loads the private key from -key-file, the existing configuration or the key store.
+// A config without a private key falls back to the key stored for its device.
+func loadRenewKey(cfg *config.Config, existing *wireguard.ExistingConfig, store *keystore.Store) (*keys.KeyPair, error) {
+	var privateKey string
+	switch {
+	case cfg.KeyFile != "":
+		var err error
+		if privateKey, err = wireguard.ReadKeyFile(cfg.KeyFile); err != nil {
+			return nil, err
+		}
+		// Rewriting the header in place would leave the config with a key the certificate isn't for
+		if existing != nil && existing.HasPrivateKey() && !cfg.Reselect && existing.PrivateKey != privateKey {
+			return nil, fmt.Errorf("key in %s doesn't match %s (use -reselect to write a new configuration)", cfg.KeyFile, existing.Path)
+		}
+	case existing != nil && existing.HasPrivateKey():
+		privateKey = existing.PrivateKey
+	default:
+		// Peer-only configs and configs for keys generated on the device have no key to read
+		clientKey, err := lookupKey(store, cfg.DeviceName)
+		if err != nil {
+			return nil, err
+		}
+		if clientKey == nil && existing != nil {
+			return nil, errclass.New(errclass.InvalidInput, "%s has no private key (peer-only or -public-key config): pass -key-file, or the -device-name of a stored key", existing.Path)
+		}
+		if clientKey == nil {
+			return nil, fmt.Errorf("no key to renew: %s doesn't exist (use -output, -key-file or the -device-name of a stored key)", cfg.OutputFile)
+		}
+		return clientKey, nil
+	}
+
+	clientKey, err := keys.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+	return clientKey, nil
+}
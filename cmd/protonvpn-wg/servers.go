@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/vpn"
+)
+
+// runServers lists the servers matching the selection flags, best first
+func runServers(ctx context.Context, args []string) error {
+	cfg, err := config.ParseServers(args)
+	if err != nil {
+		return usageError(err, config.PrintServersUsage)
+	}
+
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	servers, err := vpn.NewClient(cfg, authClient.API()).GetServers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get servers: %w", err)
+	}
+	vpn.SaveServerCache(servers)
+
+	eligible := vpn.NewServerSelector(cfg).Eligible(servers)
+	if len(eligible) == 0 {
+		fmt.Println("No servers match")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SERVER\tCOUNTRY\tCITY\tTIER\tLOAD\tSCORE\tFEATURES")
+	for i := range eligible {
+		features := "-"
+		if names := api.GetFeatureNames(eligible[i].Features); len(names) > 0 {
+			features = strings.Join(names, ", ")
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d%%\t%.2f\t%s\n",
+			eligible[i].Name,
+			eligible[i].ExitCountry,
+			eligible[i].City,
+			api.GetTierName(eligible[i].Tier),
+			eligible[i].Load,
+			eligible[i].Score,
+			features)
+	}
+	return w.Flush()
+}
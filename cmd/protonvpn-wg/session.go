@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/pkg/timeutil"
+)
+
+// runLogin authenticates and saves the session, so later commands run without prompting
+func runLogin(ctx context.Context, args []string) error {
+	cfg, err := config.ParseLogin(args)
+	if err != nil {
+		return usageError(err, config.PrintLoginUsage)
+	}
+
+	authClient := auth.NewClient(cfg)
+	if _, err := authClient.Authenticate(ctx); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	fmt.Printf("Logged in as %s, session saved to %s\n", cfg.Username, auth.NewSessionStore().GetPath())
+	return nil
+}
+
+// runLogout revokes and deletes the saved session
+func runLogout(ctx context.Context, args []string) error {
+	cfg, err := config.ParseLogout(args)
+	if err != nil {
+		return usageError(err, config.PrintLogoutUsage)
+	}
+
+	savedSession, err := auth.NewClient(cfg).Logout(ctx)
+	if err != nil {
+		return err
+	}
+	if savedSession == nil {
+		fmt.Println("No saved session")
+		return nil
+	}
+
+	fmt.Printf("Logged out %s\n", savedSession.Username)
+	return nil
+}
+
+// runSession shows the saved session without contacting the API
+func runSession(args []string) error {
+	if _, err := config.ParseSession(args); err != nil {
+		return usageError(err, config.PrintSessionUsage)
+	}
+
+	store := auth.NewSessionStore()
+	savedSession, err := store.Info()
+	if err != nil {
+		return err
+	}
+	if savedSession == nil {
+		fmt.Printf("No saved session (%s)\n", store.GetPath())
+		return nil
+	}
+
+	fmt.Printf("Session file: %s\n", store.GetPath())
+	fmt.Printf("Username:     %s\n", savedSession.Username)
+	fmt.Printf("Saved:        %s\n", savedSession.SavedAt.Local().Format("2006-01-02 15:04 MST"))
+	if remaining := time.Until(savedSession.ExpiresAt); remaining > 0 {
+		fmt.Printf("Expires:      %s (in %s)\n", savedSession.ExpiresAt.Local().Format("2006-01-02 15:04 MST"), timeutil.HumanizeDuration(remaining))
+	} else {
+		fmt.Printf("Expires:      %s (expired)\n", savedSession.ExpiresAt.Local().Format("2006-01-02 15:04 MST"))
+	}
+	if savedSession.Session != nil {
+		fmt.Printf("Scopes:       %s\n", strings.Join(savedSession.Session.Scopes, ", "))
+	}
+	return nil
+}
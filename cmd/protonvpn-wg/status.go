@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/pkg/timeutil"
+	"protonvpn-wg-config-generate/pkg/wireguard"
+)
+
+// certificateStatus is the expiry information of one config file or stored key
+type certificateStatus struct {
+	name      string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+// runStatus reports the time to expiry of certificates and fails if any expires within the
+// warning threshold, so it can be used for alerting
+func runStatus(args []string) error {
+	cfg, err := config.ParseStatus(args)
+	if err != nil {
+		return usageError(err, config.PrintStatusUsage)
+	}
+
+	var statuses []certificateStatus
+	if len(cfg.StatusTargets) > 0 {
+		statuses, err = configStatuses(cfg.StatusTargets)
+	} else {
+		statuses, err = keyStoreStatuses(cfg)
+	}
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		fmt.Println("No certificates to check")
+		return nil
+	}
+
+	if expiring := printStatuses(statuses, cfg.ExpiryWarning, time.Now()); expiring > 0 {
+		return fmt.Errorf("%d certificate(s) expired or expiring within %s", expiring, timeutil.HumanizeDuration(cfg.ExpiryWarning))
+	}
+	return nil
+}
+
+// configStatuses reads the expiry recorded in the headers of config files
+func configStatuses(paths []string) ([]certificateStatus, error) {
+	statuses := make([]certificateStatus, 0, len(paths))
+	for _, path := range paths {
+		existing, err := wireguard.ReadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, certificateStatus{name: path, expiresAt: existing.ExpiresAt, refreshAt: existing.RefreshAt})
+	}
+	return statuses, nil
+}
+
+// keyStoreStatuses reads the expiry of all certificates in the key store
+func keyStoreStatuses(cfg *config.Config) ([]certificateStatus, error) {
+	store, err := openKeyStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]certificateStatus, 0, len(entries))
+	for i := range entries {
+		status := certificateStatus{name: entries[i].DeviceName}
+		if entries[i].ExpirationTime > 0 {
+			status.expiresAt = time.Unix(entries[i].ExpirationTime, 0)
+		}
+		if entries[i].RefreshTime > 0 {
+			status.refreshAt = time.Unix(entries[i].RefreshTime, 0)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// printStatuses prints a table of certificate statuses and returns the number of
+// certificates that have expired or expire within warn
+func printStatuses(statuses []certificateStatus, warn time.Duration, now time.Time) int {
+	var expiring int
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tEXPIRES\tREMAINING\tSTATUS")
+	for _, status := range statuses {
+		// Unknown for configs generated before expiry was recorded and for imported keys
+		if status.expiresAt.IsZero() {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\tunknown\n", status.name)
+			continue
+		}
+
+		remaining := status.expiresAt.Sub(now)
+		state := "ok"
+		switch {
+		case remaining <= 0:
+			state = "expired"
+			expiring++
+		case remaining <= warn:
+			state = "expiring"
+			expiring++
+		case !status.refreshAt.IsZero() && now.After(status.refreshAt):
+			state = "refresh due"
+		}
+
+		remainingStr := "-"
+		if remaining > 0 {
+			remainingStr = timeutil.HumanizeDuration(remaining)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.name, status.expiresAt.Local().Format("2006-01-02 15:04"), remainingStr, state)
+	}
+	_ = w.Flush()
+
+	return expiring
+}
@@ -3,14 +3,16 @@ module protonvpn-wg-config-generate
 go 1.25.5
 
 require (
+	filippo.io/edwards25519 v1.1.0
+	github.com/ProtonMail/go-crypto v1.3.0
 	github.com/ProtonMail/go-srp v0.0.7
 	github.com/ProtonVPN/go-vpn-lib v0.0.0-20251126054500-e7bed91ad40f
 	golang.org/x/term v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/ProtonMail/bcrypt v0.0.0-20211005172633-e235017c1baf // indirect
-	github.com/ProtonMail/go-crypto v1.3.0 // indirect
 	github.com/cloudflare/circl v1.6.1 // indirect
 	github.com/cronokirby/saferith v0.33.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
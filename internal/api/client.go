@@ -0,0 +1,364 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
+)
+
+// maxErrorBodyLength limits how much of a non-JSON error body is kept in an Error
+const maxErrorBodyLength = 512
+
+// Client is the HTTP client shared by everything that talks to the ProtonVPN API.
+// It injects the standard and session headers, decodes the Code/Error envelope
+// into typed errors and transparently refreshes the access token on 401 responses.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+
+	mu        sync.Mutex
+	refreshMu sync.Mutex
+	session   *Session
+	onRefresh func(*Session)
+}
+
+// ClientOption customizes a Client
+type ClientOption func(*Client)
+
+// WithTimeout sets the time limit for a single API call, including all retries
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// WithRetries sets how many times a failed API call is retried
+func WithRetries(retries int) ClientOption {
+	return func(c *Client) {
+		if retries >= 0 {
+			c.retry.MaxRetries = retries
+		}
+	}
+}
+
+// NewClient creates a new API client
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL: baseURL,
+		timeout: constants.DefaultAPITimeout,
+		retry:   DefaultRetryPolicy(),
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: false,
+					MinVersion:         tls.VersionTLS12,
+				},
+			},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetSession sets the session used to authenticate requests (nil for none)
+func (c *Client) SetSession(session *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = session
+}
+
+// Session returns the session currently used to authenticate requests
+func (c *Client) Session() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// OnRefresh registers a function that is called with the new session after every token refresh,
+// typically to persist it
+func (c *Client) OnRefresh(fn func(*Session)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRefresh = fn
+}
+
+// RequestOption customizes a single request
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	headers         map[string]string
+	allowedCodes    []int
+	unauthenticated bool
+	noRefresh       bool
+	noRetry         bool
+}
+
+// WithHeader adds an extra header to the request
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		o.headers[key] = value
+	}
+}
+
+// WithAllowedCodes makes the given response codes decode into the result instead of returning an Error
+func WithAllowedCodes(codes ...int) RequestOption {
+	return func(o *requestOptions) {
+		o.allowedCodes = append(o.allowedCodes, codes...)
+	}
+}
+
+// Unauthenticated sends the request without session headers, as used before a session exists
+func Unauthenticated() RequestOption {
+	return func(o *requestOptions) {
+		o.unauthenticated = true
+	}
+}
+
+// WithoutRefresh disables the automatic token refresh on 401 responses
+func WithoutRefresh() RequestOption {
+	return func(o *requestOptions) {
+		o.noRefresh = true
+	}
+}
+
+// WithoutRetry disables retries, for requests that must not be replayed such as SRP proofs
+func WithoutRetry() RequestOption {
+	return func(o *requestOptions) {
+		o.noRetry = true
+	}
+}
+
+// Do sends a request to the API. The body (if not nil) is encoded as JSON and a successful
+// response is decoded into out (if not nil). Non-success responses are returned as *Error.
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}, opts ...RequestOption) error {
+	options := &requestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	session := c.Session()
+	if options.unauthenticated {
+		session = nil
+	}
+
+	resp, err := c.sendWithRetry(ctx, method, path, body, session, options)
+	if err != nil {
+		return err
+	}
+
+	if resp.status == http.StatusUnauthorized && session != nil && !options.noRefresh {
+		refreshed, err := c.refresh(ctx, session)
+		if err != nil {
+			return fmt.Errorf("access token expired and refresh failed: %w", err)
+		}
+
+		resp, err = c.sendWithRetry(ctx, method, path, body, refreshed, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	return decodeResponse(resp.status, resp.body, out, options)
+}
+
+// Ping sends an unauthenticated request to check that the API is reachable and accepts the app
+// version. It returns the server time from the Date header, which is zero if the header is
+// missing, also when the API returns an error.
+func (c *Client) Ping(ctx context.Context) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	options := &requestOptions{}
+	resp, err := c.sendWithRetry(ctx, http.MethodGet, constants.PingPath, nil, nil, options)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	serverTime, _ := http.ParseTime(resp.header.Get("Date"))
+	return serverTime, decodeResponse(resp.status, resp.body, nil, options)
+}
+
+// RefreshSession exchanges the refresh token of the current session for new tokens
+func (c *Client) RefreshSession(ctx context.Context) (*Session, error) {
+	session := c.Session()
+	if session == nil {
+		return nil, errors.New("no session to refresh")
+	}
+	return c.refresh(ctx, session)
+}
+
+// refresh replaces the given session with a refreshed one. Concurrent callers holding the
+// same expired session share a single refresh.
+func (c *Client) refresh(ctx context.Context, oldSession *Session) (*Session, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if current := c.Session(); current != nil && current != oldSession {
+		return current, nil
+	}
+
+	// Based on WebClients source, the refresh endpoint is /auth/refresh
+	reqBody := map[string]interface{}{
+		"ResponseType": "token",
+		"GrantType":    "refresh_token",
+		"RefreshToken": oldSession.RefreshToken,
+		"RedirectURI":  "https://protonmail.com",
+	}
+
+	options := &requestOptions{}
+	resp, err := c.sendWithRetry(ctx, http.MethodPost, constants.RefreshPath, reqBody, oldSession, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var refreshed Session
+	if err := decodeResponse(resp.status, resp.body, &refreshed, options); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.session = &refreshed
+	onRefresh := c.onRefresh
+	c.mu.Unlock()
+
+	slog.Debug("Access token refreshed", "session", &refreshed)
+	if onRefresh != nil {
+		onRefresh(&refreshed)
+	}
+
+	return &refreshed, nil
+}
+
+// response holds the parts of an HTTP response the client needs
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// sendWithRetry sends a request, retrying transient failures according to the retry policy
+func (c *Client) sendWithRetry(ctx context.Context, method, path string, body interface{}, session *Session, options *requestOptions) (*response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body, session, options)
+		if options.noRetry || attempt >= c.retry.MaxRetries || !isRetryable(ctx, method, resp, err) {
+			return resp, err
+		}
+
+		delay := c.retry.Delay(attempt, resp)
+		slog.Debug("Retrying API request", "method", method, "endpoint", path, "attempt", attempt+1, "delay", delay)
+		if !sleep(ctx, delay) {
+			return resp, err
+		}
+	}
+}
+
+// send performs a single HTTP round trip. Bodies are never logged, since they carry passwords,
+// proofs and tokens.
+func (c *Client) send(ctx context.Context, method, path string, body interface{}, session *Session, options *requestOptions) (*response, error) {
+	reqBody := io.Reader(http.NoBody)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-pm-appversion", constants.AppVersion)
+	req.Header.Set("User-Agent", constants.UserAgent)
+	if session != nil {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", session.AccessToken))
+		req.Header.Set("x-pm-uid", session.UID)
+	}
+	for key, value := range options.headers {
+		req.Header.Set(key, value)
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		slog.Debug("API request failed", "method", method, "endpoint", path, "latency", time.Since(start), "error", err)
+		return nil, errclass.Wrap(errclass.Network, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errclass.Wrap(errclass.Network, err)
+	}
+	slog.Debug("API request", "method", method, "endpoint", path, "status", resp.StatusCode, "latency", time.Since(start), "bytes", len(respBody))
+
+	return &response{
+		status: resp.StatusCode,
+		header: resp.Header,
+		body:   respBody,
+	}, nil
+}
+
+// decodeResponse checks the response envelope and decodes the body into out
+func decodeResponse(status int, respBody []byte, out interface{}, options *requestOptions) error {
+	var env envelope
+	if err := json.Unmarshal(respBody, &env); err != nil {
+		if status != http.StatusOK {
+			return &Error{Status: status, Message: truncate(string(respBody), maxErrorBodyLength)}
+		}
+		return fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	allowed := slices.Contains(options.allowedCodes, env.Code)
+	if !allowed && (status != http.StatusOK || env.Code != constants.APICodeSuccess) {
+		return &Error{
+			Status:  status,
+			Code:    env.Code,
+			Message: env.Error,
+			Details: env.Details,
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse API response: %w", err)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
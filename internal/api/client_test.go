@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+func TestDoRefreshesOn401(t *testing.T) {
+	var refreshCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case constants.RefreshPath:
+			refreshCalls++
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"Code":         constants.APICodeSuccess,
+				"AccessToken":  "new-access",
+				"RefreshToken": "new-refresh",
+				"UID":          "uid",
+			})
+		case constants.LogicalsPath:
+			if r.Header.Get("Authorization") != "Bearer new-access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"Code": 401, "Error": "Invalid access token"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Code": constants.APICodeSuccess})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetSession(&Session{AccessToken: "old-access", RefreshToken: "old-refresh", UID: "uid"})
+
+	var persisted *Session
+	client.OnRefresh(func(s *Session) { persisted = s })
+
+	var resp LogicalsResponse
+	if err := client.Do(context.Background(), http.MethodGet, constants.LogicalsPath, nil, &resp); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+
+	if refreshCalls != 1 {
+		t.Errorf("Expected 1 refresh call, got %d", refreshCalls)
+	}
+	if persisted == nil || persisted.RefreshToken != "new-refresh" {
+		t.Errorf("Expected refreshed session to be persisted, got %+v", persisted)
+	}
+	if client.Session().AccessToken != "new-access" {
+		t.Errorf("Expected client to use refreshed session, got %q", client.Session().AccessToken)
+	}
+}
+
+func TestDoDecodesErrorEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"Code":9001,"Error":"CAPTCHA required","Details":{"HumanVerificationToken":"abc"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	err := client.Do(context.Background(), http.MethodPost, constants.AuthPath, map[string]string{}, nil, Unauthenticated())
+
+	apiErr, ok := AsError(err)
+	if !ok {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if apiErr.Code != 9001 || apiErr.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Unexpected error fields: %+v", apiErr)
+	}
+
+	var details HumanVerificationDetails
+	if err := apiErr.DecodeDetails(&details); err != nil || details.HumanVerificationToken != "abc" {
+		t.Errorf("Expected details to decode, got %+v (%v)", details, err)
+	}
+}
+
+func TestDoAllowedCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"Code":10013,"AccessToken":"token"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	var session Session
+	if err := client.Do(context.Background(), http.MethodPost, constants.AuthPath, nil, &session, WithAllowedCodes(10013)); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if session.AccessToken != "token" {
+		t.Errorf("Expected response to be decoded, got %+v", session)
+	}
+}
+
+func TestDoRetriesTransientFailures(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Code": constants.APICodeSuccess})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithRetries(2))
+	if err := client.Do(context.Background(), http.MethodGet, constants.LogicalsPath, nil, nil); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+
+	calls = 0
+	if err := client.Do(context.Background(), http.MethodPost, constants.AuthPath, nil, nil, WithoutRetry()); err == nil {
+		t.Error("Expected error without retry")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call without retry, got %d", calls)
+	}
+}
+
+func TestDoDoesNotResendPostAfterConnectionReset(t *testing.T) {
+	calls := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method]++
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithRetries(2))
+	client.retry.BaseDelay = time.Millisecond
+	if err := client.Do(context.Background(), http.MethodPost, constants.RefreshPath, map[string]string{}, nil); err == nil {
+		t.Error("Expected an error for a reset connection")
+	}
+	if calls[http.MethodPost] != 1 {
+		t.Errorf("Expected the POST to be sent once, got %d", calls[http.MethodPost])
+	}
+
+	if err := client.Do(context.Background(), http.MethodGet, constants.LogicalsPath, nil, nil); err == nil {
+		t.Error("Expected an error for a reset connection")
+	}
+	if calls[http.MethodGet] != 3 {
+		t.Errorf("Expected the GET to be retried twice, got %d calls", calls[http.MethodGet])
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"protonvpn-wg-config-generate/internal/errclass"
+)
+
+// Error represents an API call that failed at the HTTP level or returned a non-success code
+type Error struct {
+	Status  int             // HTTP status code
+	Code    int             // Proton response code, 0 if the body was not an API envelope
+	Message string          // Error message from the API, if any
+	Details json.RawMessage // Error details from the API, if any
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	switch {
+	case e.Code != 0 && e.Message != "":
+		return fmt.Sprintf("API error (code %d): %s", e.Code, e.Message)
+	case e.Code != 0:
+		return fmt.Sprintf("API error (code %d, HTTP %d)", e.Code, e.Status)
+	default:
+		return fmt.Sprintf("HTTP error %d", e.Status)
+	}
+}
+
+// ErrorClass implements errclass.Classifier
+func (e *Error) ErrorClass() errclass.Class {
+	return errclass.API
+}
+
+// DecodeDetails unmarshals the error details into v
+func (e *Error) DecodeDetails(v interface{}) error {
+	if len(e.Details) == 0 {
+		return fmt.Errorf("API error has no details")
+	}
+	return json.Unmarshal(e.Details, v)
+}
+
+// AsError returns the API error wrapped in err, if any
+func AsError(err error) (*Error, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// envelope holds the fields common to every API response
+type envelope struct {
+	Code    int             `json:"Code"`
+	Error   string          `json:"Error"`
+	Details json.RawMessage `json:"Details"`
+}
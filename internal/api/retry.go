@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// RetryPolicy controls how transient API failures are retried
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used unless configured otherwise
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: constants.DefaultAPIRetries,
+		BaseDelay:  constants.RetryBaseDelay,
+		MaxDelay:   constants.RetryMaxDelay,
+	}
+}
+
+// Delay returns how long to wait before the next attempt. A Retry-After header on the
+// response takes precedence, otherwise exponential backoff with full jitter is used.
+func (p RetryPolicy) Delay(attempt int, resp *response) time.Duration {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+
+	backoff := p.MaxDelay
+	if shift := uint(attempt); shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		backoff = p.BaseDelay << shift
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	//nolint:gosec // jitter does not need a cryptographically secure source
+	return time.Duration(rand.Int64N(int64(backoff))) + 1
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// isRetryable reports whether a failed attempt is worth retrying. A request that failed on the
+// network or with a server error may have been processed, so only idempotent methods are
+// retried then: replaying a POST could reuse a rotated refresh token or a one-time 2FA code.
+func isRetryable(ctx context.Context, method string, resp *response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		// Network errors are transient unless caused by our own cancellation
+		return isIdempotent(method) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	if resp.status == http.StatusTooManyRequests {
+		return true
+	}
+	return isIdempotent(method) && resp.status >= http.StatusInternalServerError
+}
+
+// isIdempotent reports whether sending a request with the method twice has the same effect as
+// sending it once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for the given delay, returning false if the context ends first or its
+// deadline leaves no time for the delay
+func sleep(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
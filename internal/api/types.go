@@ -1,6 +1,11 @@
 // Package api defines the data structures for ProtonVPN API responses.
 package api
 
+import (
+	"fmt"
+	"log/slog"
+)
+
 // AuthInfoResponse represents the response from the auth info endpoint
 type AuthInfoResponse struct {
 	Code            int    `json:"Code"`
@@ -24,6 +29,12 @@ type AuthRequest struct {
 	TwoFactorCode   string `json:"TwoFactorCode,omitempty"`
 }
 
+// HumanVerificationDetails represents the error details of a response that requires human verification (CAPTCHA)
+type HumanVerificationDetails struct {
+	HumanVerificationMethods []string `json:"HumanVerificationMethods"`
+	HumanVerificationToken   string   `json:"HumanVerificationToken"`
+}
+
 // Session represents a ProtonVPN session
 type Session struct {
 	Code         int      `json:"Code"`
@@ -43,27 +54,80 @@ type Session struct {
 	} `json:"2FA"`
 }
 
+// LogValue logs the session without its tokens
+func (s *Session) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("uid", s.UID),
+		slog.Any("scopes", s.Scopes),
+		slog.Int("expires_in", s.ExpiresIn),
+	)
+}
+
+// KeySaltsResponse represents the response from the key salts endpoint
+type KeySaltsResponse struct {
+	Code     int `json:"Code"`
+	KeySalts []struct {
+		ID      string `json:"ID"`
+		KeySalt string `json:"KeySalt"`
+	} `json:"KeySalts"`
+}
+
+// UserResponse represents the response from the users endpoint
+type UserResponse struct {
+	Code int `json:"Code"`
+	User struct {
+		ID   string `json:"ID"`
+		Name string `json:"Name"`
+		Keys []struct {
+			ID         string `json:"ID"`
+			PrivateKey string `json:"PrivateKey"`
+			Primary    int    `json:"Primary"`
+		} `json:"Keys"`
+	} `json:"User"`
+}
+
+// TwoFactorResponse represents the response from the 2FA endpoint
+type TwoFactorResponse struct {
+	Code   int      `json:"Code"`
+	Scopes []string `json:"Scopes"`
+}
+
+// ScopesResponse represents the response from the auth scopes endpoint
+type ScopesResponse struct {
+	Code   int      `json:"Code"`
+	Scopes []string `json:"Scopes"`
+}
+
 // VPNInfo represents VPN certificate information
 type VPNInfo struct {
-	Code                 int    `json:"Code"`
-	Error                string `json:"Error,omitempty"`
-	SerialNumber         string `json:"SerialNumber"`
-	ClientKeyFingerprint string `json:"ClientKeyFingerprint"`
-	ClientKey            string `json:"ClientKey"`
-	Certificate          string `json:"Certificate"`
-	ExpirationTime       int64  `json:"ExpirationTime"`
-	RefreshTime          int64  `json:"RefreshTime"`
-	Mode                 string `json:"Mode"`
-	DeviceName           string `json:"DeviceName"`
-	ServerPublicKeyMode  string `json:"ServerPublicKeyMode"`
-	ServerPublicKey      string `json:"ServerPublicKey"`
-	Features             struct {
-		Bouncing       bool `json:"bouncing"`
-		ModerateNAT    bool `json:"moderate-nat"`
-		NetshieldLevel int  `json:"netshield-level"`
-		PortForwarding bool `json:"port-forwarding"`
-		VPNAccelerator bool `json:"vpn-accelerator"`
-	} `json:"Features"`
+	Code                 int                 `json:"Code"`
+	Error                string              `json:"Error,omitempty"`
+	SerialNumber         string              `json:"SerialNumber"`
+	ClientKeyFingerprint string              `json:"ClientKeyFingerprint"`
+	ClientKey            string              `json:"ClientKey"`
+	Certificate          string              `json:"Certificate"`
+	ExpirationTime       int64               `json:"ExpirationTime"`
+	RefreshTime          int64               `json:"RefreshTime"`
+	Mode                 string              `json:"Mode"`
+	DeviceName           string              `json:"DeviceName"`
+	ServerPublicKeyMode  string              `json:"ServerPublicKeyMode"`
+	ServerPublicKey      string              `json:"ServerPublicKey"`
+	Features             CertificateFeatures `json:"Features"`
+}
+
+// CertificateFeatures represents the features enabled for a VPN certificate
+type CertificateFeatures struct {
+	Bouncing       bool `json:"bouncing"`
+	ModerateNAT    bool `json:"moderate-nat"`
+	NetshieldLevel int  `json:"netshield-level"`
+	PortForwarding bool `json:"port-forwarding"`
+	VPNAccelerator bool `json:"vpn-accelerator"`
+}
+
+// CertificatesResponse represents the response from the certificate listing endpoint
+type CertificatesResponse struct {
+	Code         int       `json:"Code"`
+	Certificates []VPNInfo `json:"Certificates"`
 }
 
 // LogicalServer represents a ProtonVPN logical server
@@ -143,6 +207,20 @@ func GetTierName(tier int) string {
 	}
 }
 
+// String returns a compact summary of the certificate features
+func (f CertificateFeatures) String() string {
+	return fmt.Sprintf("NetShield:%d Accelerator:%s ModerateNAT:%s PortForwarding:%s",
+		f.NetshieldLevel, onOff(f.VPNAccelerator), onOff(f.ModerateNAT), onOff(f.PortForwarding))
+}
+
+// onOff formats a boolean feature flag
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
 // GetFeatureNames returns a list of enabled features for a server
 func GetFeatureNames(features int) []string {
 	var result []string
@@ -2,85 +2,72 @@
 package auth
 
 import (
-	"bufio"
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"strings"
 	"time"
 
 	"protonvpn-wg-config-generate/internal/api"
 	"protonvpn-wg-config-generate/internal/config"
 	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/internal/prompt"
 	"protonvpn-wg-config-generate/pkg/timeutil"
 
 	"github.com/ProtonMail/go-srp"
-	"golang.org/x/term"
 )
 
 // Client handles ProtonVPN authentication
 type Client struct {
 	config       *config.Config
-	httpClient   *http.Client
+	api          *api.Client
 	sessionStore *SessionStore
 }
 
 // NewClient creates a new authentication client
 func NewClient(cfg *config.Config) *Client {
-	return &Client{
+	c := &Client{
 		config:       cfg,
+		api:          api.NewClient(cfg.APIURL, api.WithRetries(cfg.Retries), api.WithTimeout(cfg.Timeout)),
 		sessionStore: NewSessionStore(),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: false,
-					MinVersion:         tls.VersionTLS12,
-				},
-			},
-		},
 	}
+
+	// Persist sessions rotated by a refresh, including automatic ones on 401 responses
+	c.api.OnRefresh(c.saveSessionIfEnabled)
+	return c
+}
+
+// API returns the shared API client, which carries the session once Authenticate succeeds
+func (c *Client) API() *api.Client {
+	return c.api
 }
 
-// handleSessionRefresh attempts to refresh a session and save it if successful
-func (c *Client) handleSessionRefresh(savedSession *api.Session, reason string) (*api.Session, error) {
-	fmt.Println(reason)
-	refreshedSession, err := RefreshSession(c.httpClient, c.config.APIURL, savedSession)
+// handleSessionRefresh attempts to refresh a session; the refreshed session is saved by the API client hook
+func (c *Client) handleSessionRefresh(ctx context.Context, savedSession *api.Session, reason string) (*api.Session, error) {
+	slog.Info(reason)
+	c.api.SetSession(savedSession)
+	refreshedSession, err := c.api.RefreshSession(ctx)
 	if err != nil {
-		fmt.Printf("Token refresh failed: %v\n", err)
-		fmt.Println("Re-authenticating with password...")
-		fmt.Println("(Your trusted device status for MFA will be preserved)")
+		// The trusted device status for MFA is preserved
+		slog.Warn("Token refresh failed, re-authenticating with password", "error", err)
+		c.api.SetSession(nil)
 		_ = c.sessionStore.Delete()
 		return nil, err
 	}
 
-	fmt.Println("Session refreshed successfully!")
-	// Check if refresh token was rotated
-	if savedSession.RefreshToken != refreshedSession.RefreshToken {
-		fmt.Println("Refresh token was rotated")
-	}
-
-	// Save the refreshed session
-	if !c.config.NoSession {
-		sessionDuration, _ := timeutil.ParseSessionDuration(c.config.SessionDuration)
-		if err := c.sessionStore.Save(refreshedSession, c.config.Username, sessionDuration); err != nil {
-			fmt.Printf("Warning: Failed to save refreshed session: %v\n", err)
-		}
-	}
+	slog.Info("Session refreshed", "token_rotated", savedSession.RefreshToken != refreshedSession.RefreshToken)
 
 	return refreshedSession, nil
 }
 
 // tryExistingSession attempts to use an existing saved session
-func (c *Client) tryExistingSession() (*api.Session, error) {
+func (c *Client) tryExistingSession(ctx context.Context) (*api.Session, error) {
 	savedSession, timeUntilExpiry, err := c.sessionStore.Load(c.config.Username)
 	if err != nil {
-		fmt.Printf("Warning: Failed to load saved session: %v\n", err)
+		slog.Warn("Failed to load saved session", "error", err)
 		return nil, err
 	}
 
@@ -88,62 +75,107 @@ func (c *Client) tryExistingSession() (*api.Session, error) {
 		return nil, nil
 	}
 
+	// A dry run leaves the saved session as it is, since a refresh rotates its tokens and the
+	// new ones aren't saved
+	if c.config.DryRun {
+		if err := c.checkSessionWithoutRefresh(ctx, savedSession); err != nil {
+			slog.Info("Saved session can't be used without a refresh, re-authenticating", "error", err)
+			c.api.SetSession(nil)
+			return nil, nil
+		}
+		slog.Info("Using saved session", "expires_in", timeutil.HumanizeDuration(timeUntilExpiry))
+		return savedSession, nil
+	}
+
 	// Determine what to do with the saved session
 	switch {
 	case c.config.ForceRefresh:
 		reason := fmt.Sprintf("Forcing session refresh (current session expires in %s)", timeutil.HumanizeDuration(timeUntilExpiry))
-		return c.handleSessionRefresh(savedSession, reason)
+		return c.handleSessionRefresh(ctx, savedSession, reason)
 
 	case timeUntilExpiry < time.Duration(constants.SessionRefreshDays)*24*time.Hour && timeUntilExpiry > 0:
 		reason := fmt.Sprintf("Session expires soon (in %s), attempting refresh...", timeutil.HumanizeDuration(timeUntilExpiry))
-		return c.handleSessionRefresh(savedSession, reason)
+		return c.handleSessionRefresh(ctx, savedSession, reason)
 
-	case VerifySession(c.httpClient, c.config.APIURL, savedSession):
-		fmt.Printf("Using saved session (expires in %s)\n", timeutil.HumanizeDuration(timeUntilExpiry))
-		return savedSession, nil
+	case VerifySession(ctx, c.apiWithSession(savedSession)):
+		slog.Info("Using saved session", "expires_in", timeutil.HumanizeDuration(timeUntilExpiry))
+		// Verification may have refreshed the access token
+		return c.api.Session(), nil
 
 	default:
-		fmt.Println("Saved session invalid, re-authenticating...")
+		slog.Info("Saved session invalid, re-authenticating")
+		c.api.SetSession(nil)
 		_ = c.sessionStore.Delete()
 		return nil, nil
 	}
 }
 
+// checkSessionWithoutRefresh checks that the API accepts the session as it is and that it has the
+// VPN scope, using the scopes endpoint rather than downloading the server list
+func (c *Client) checkSessionWithoutRefresh(ctx context.Context, session *api.Session) error {
+	var scopesResp api.ScopesResponse
+	if err := c.apiWithSession(session).Do(ctx, http.MethodGet, constants.ScopesPath, nil, &scopesResp, api.WithoutRefresh()); err != nil {
+		return err
+	}
+	if hasVPN, _ := CheckSessionScopes(&api.Session{Scopes: scopesResp.Scopes}); !hasVPN {
+		return errors.New("the session lacks the VPN scope")
+	}
+	return nil
+}
+
+// apiWithSession sets the session on the shared API client and returns it
+func (c *Client) apiWithSession(session *api.Session) *api.Client {
+	c.api.SetSession(session)
+	return c.api
+}
+
 // Authenticate performs the full authentication flow
-func (c *Client) Authenticate() (*api.Session, error) {
-	if err := c.ensureUsername(); err != nil {
+func (c *Client) Authenticate(ctx context.Context) (*api.Session, error) {
+	if err := c.ensureUsername(ctx); err != nil {
 		return nil, err
 	}
 
 	// Try existing session unless clearing or disabled
-	if session := c.handleExistingSession(); session != nil {
+	if session := c.handleExistingSession(ctx); session != nil {
 		return session, nil
 	}
 
-	if err := c.ensurePassword(); err != nil {
+	if err := c.ensurePassword(ctx); err != nil {
 		return nil, err
 	}
 
 	// Perform fresh authentication
-	session, err := c.performFreshAuth()
+	session, err := c.performFreshAuth(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	c.api.SetSession(session)
+
+	// Complete the mailbox unlock step for 2-password mode accounts
+	if needsMailboxUnlock(session) {
+		if err := c.unlockMailbox(ctx, session); err != nil {
+			return nil, fmt.Errorf("failed to unlock mailbox: %w", err)
+		}
+	}
+
 	// Handle session scope upgrade if needed
-	if err := c.upgradeSessionIfNeeded(session); err != nil {
+	if err := c.upgradeSessionIfNeeded(ctx, session); err != nil {
 		return nil, err
 	}
 
+	slog.Debug("Authenticated", "session", session)
 	c.saveSessionIfEnabled(session)
 	return session, nil
 }
 
 // handleExistingSession handles session clearing or reuse
-func (c *Client) handleExistingSession() *api.Session {
+func (c *Client) handleExistingSession(ctx context.Context) *api.Session {
 	if c.config.ClearSession {
-		fmt.Println("Clearing saved session...")
-		_ = c.sessionStore.Delete()
+		if !c.config.DryRun {
+			slog.Info("Clearing saved session")
+			_ = c.sessionStore.Delete()
+		}
 		return nil
 	}
 
@@ -151,16 +183,40 @@ func (c *Client) handleExistingSession() *api.Session {
 		return nil
 	}
 
-	session, err := c.tryExistingSession()
+	session, err := c.tryExistingSession(ctx)
 	if err == nil && session != nil {
 		return session
 	}
 	return nil
 }
 
+// HumanVerification holds a completed human verification to attach to an auth request
+type HumanVerification struct {
+	Type  string
+	Token string
+}
+
 // performFreshAuth performs SRP authentication and returns a new session
-func (c *Client) performFreshAuth() (*api.Session, error) {
-	authInfo, err := c.getAuthInfo()
+func (c *Client) performFreshAuth(ctx context.Context) (*api.Session, error) {
+	session, err := c.srpAuth(ctx, nil)
+
+	var hvErr *HumanVerificationError
+	if !errors.As(err, &hvErr) {
+		return session, err
+	}
+
+	verification, err := c.completeHumanVerification(ctx, hvErr)
+	if err != nil {
+		return nil, err
+	}
+
+	// SRP ephemerals are single-use, so the whole exchange is repeated with the verification attached
+	return c.srpAuth(ctx, verification)
+}
+
+// srpAuth runs a single SRP exchange, optionally carrying a completed human verification
+func (c *Client) srpAuth(ctx context.Context, verification *HumanVerification) (*api.Session, error) {
+	authInfo, err := c.getAuthInfo(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get auth info: %w", err)
 	}
@@ -174,26 +230,53 @@ func (c *Client) performFreshAuth() (*api.Session, error) {
 
 	// Handle 2FA if needed
 	if authInfo.TwoFA.Enabled == constants.EnabledTrue && authInfo.TwoFA.TOTP == constants.EnabledTrue {
-		code, err := c.get2FACode()
+		code, err := c.get2FACode(ctx)
 		if err != nil {
 			return nil, err
 		}
 		authReq["TwoFactorCode"] = code
 	}
 
-	session, err := c.sendAuthRequest(authReq)
+	session, err := c.sendAuthRequest(ctx, authReq, verification)
 	if err != nil {
 		return nil, err
 	}
 
 	// Verify server proof
 	if session.ServerProof != base64.StdEncoding.EncodeToString(clientProofs.ExpectedServerProof) {
-		return nil, fmt.Errorf("server proof verification failed")
+		return nil, errclass.New(errclass.AuthFailed, "server proof verification failed")
 	}
 
 	return session, nil
 }
 
+// completeHumanVerification obtains a CAPTCHA token from the -captcha-token flag or the user
+func (c *Client) completeHumanVerification(ctx context.Context, hvErr *HumanVerificationError) (*HumanVerification, error) {
+	if !hvErr.Supports(constants.HumanVerificationCaptcha) {
+		return nil, fmt.Errorf("%w - none of the offered methods are supported by this tool", hvErr)
+	}
+
+	token := c.config.CaptchaToken
+	if token == "" {
+		fmt.Println("CAPTCHA verification required.")
+		fmt.Println("Open the following URL in a browser and complete the challenge:")
+		fmt.Printf("  %s\n", hvErr.URL())
+		input, err := prompt.ReadLine(ctx, "Verification token: ")
+		if err != nil {
+			return nil, fmt.Errorf("error reading verification token: %w", err)
+		}
+		token = input
+		if token == "" {
+			return nil, errclass.New(errclass.Captcha, "verification token cannot be empty")
+		}
+	}
+
+	return &HumanVerification{
+		Type:  constants.HumanVerificationCaptcha,
+		Token: token,
+	}, nil
+}
+
 // generateSRPProofs generates SRP client proofs for authentication
 func (c *Client) generateSRPProofs(authInfo *api.AuthInfoResponse) (*srp.Proofs, error) {
 	auth, err := srp.NewAuth(
@@ -227,30 +310,30 @@ func (c *Client) buildAuthRequest(authInfo *api.AuthInfoResponse, proofs *srp.Pr
 }
 
 // upgradeSessionIfNeeded upgrades session with 2FA if VPN scope is missing
-func (c *Client) upgradeSessionIfNeeded(session *api.Session) error {
-	hasVPNScope, hasTwoFactorScope := c.checkSessionScopes(session)
+func (c *Client) upgradeSessionIfNeeded(ctx context.Context, session *api.Session) error {
+	hasVPNScope, hasTwoFactorScope := CheckSessionScopes(session)
 
 	if hasVPNScope || !hasTwoFactorScope {
 		return nil
 	}
 
-	fmt.Println("Session lacks VPN scope - 2FA verification required to upgrade session...")
-	code, err := c.get2FACode()
+	slog.Info("Session lacks VPN scope, 2FA verification required to upgrade it")
+	code, err := c.get2FACode(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get 2FA code: %w", err)
+		return errclass.Wrap(errclass.TwoFactor, fmt.Errorf("failed to get 2FA code: %w", err))
 	}
 
-	updatedScopes, err := c.submit2FA(session, code)
+	updatedScopes, err := c.submit2FA(ctx, code)
 	if err != nil {
 		return fmt.Errorf("2FA verification failed: %w", err)
 	}
 	session.Scopes = updatedScopes
-	fmt.Println("2FA verified - session upgraded with VPN scope")
+	slog.Info("2FA verified, session upgraded with VPN scope")
 	return nil
 }
 
-// checkSessionScopes checks if session has VPN and twofactor scopes
-func (c *Client) checkSessionScopes(session *api.Session) (hasVPN, hasTwoFactor bool) {
+// CheckSessionScopes checks if session has VPN and twofactor scopes
+func CheckSessionScopes(session *api.Session) (hasVPN, hasTwoFactor bool) {
 	for _, scope := range session.Scopes {
 		switch scope {
 		case "vpn":
@@ -262,60 +345,53 @@ func (c *Client) checkSessionScopes(session *api.Session) (hasVPN, hasTwoFactor
 	return
 }
 
-// saveSessionIfEnabled saves the session if persistence is enabled
+// saveSessionIfEnabled saves the session if persistence is enabled. A dry run never saves one.
 func (c *Client) saveSessionIfEnabled(session *api.Session) {
-	if c.config.NoSession {
+	if c.config.NoSession || c.config.DryRun {
 		return
 	}
 
 	sessionDuration, err := timeutil.ParseSessionDuration(c.config.SessionDuration)
 	if err != nil {
-		fmt.Printf("Warning: Invalid session duration, using default: %v\n", err)
+		slog.Warn("Invalid session duration, using default", "error", err)
 		sessionDuration = 0
 	}
 
 	if err := c.sessionStore.Save(session, c.config.Username, sessionDuration); err != nil {
-		fmt.Printf("Warning: Failed to save session: %v\n", err)
+		slog.Warn("Failed to save session", "error", err)
 	}
 }
 
-func (c *Client) ensureUsername() error {
+func (c *Client) ensureUsername(ctx context.Context) error {
 	if c.config.Username == "" {
-		fmt.Print("Username (without @protonmail.com): ")
-		reader := bufio.NewReader(os.Stdin)
-		username, err := reader.ReadString('\n')
+		username, err := prompt.ReadLine(ctx, "Username (without @protonmail.com): ")
 		if err != nil {
 			return fmt.Errorf("error reading username: %w", err)
 		}
-		c.config.Username = strings.TrimSpace(username)
+		c.config.Username = username
 		if c.config.Username == "" {
-			return fmt.Errorf("username cannot be empty")
+			return errclass.New(errclass.InvalidInput, "username cannot be empty")
 		}
 	}
 	return nil
 }
 
-func (c *Client) ensurePassword() error {
+func (c *Client) ensurePassword(ctx context.Context) error {
 	if c.config.Password == "" {
-		fmt.Print("Password: ")
-		passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
-		fmt.Println()
+		password, err := prompt.ReadPassword(ctx, "Password: ")
 		if err != nil {
 			return fmt.Errorf("error reading password: %w", err)
 		}
-		c.config.Password = string(passwordBytes)
+		c.config.Password = password
 	}
 	return nil
 }
 
-func (c *Client) get2FACode() (string, error) {
-	fmt.Print("2FA Code: ")
-	reader := bufio.NewReader(os.Stdin)
-	code, err := reader.ReadString('\n')
+func (c *Client) get2FACode(ctx context.Context) (string, error) {
+	code, err := prompt.ReadLine(ctx, "2FA Code: ")
 	if err != nil {
 		return "", fmt.Errorf("error reading 2FA code: %w", err)
 	}
-	code = strings.TrimSpace(code)
 
 	// Validate that code is numeric (TOTP codes are 6 digits)
 	if code == "" {
@@ -333,46 +409,15 @@ func (c *Client) get2FACode() (string, error) {
 	return code, nil
 }
 
-func (c *Client) getAuthInfo() (*api.AuthInfoResponse, error) {
+func (c *Client) getAuthInfo(ctx context.Context) (*api.AuthInfoResponse, error) {
 	reqBody := map[string]interface{}{
 		"Username": c.config.Username,
 		"Intent":   "Proton",
 	}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, c.config.APIURL+"/core/v4/auth/info", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-
-	c.setHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(respBody))
-	}
-
 	var authInfo api.AuthInfoResponse
-	if err := json.Unmarshal(respBody, &authInfo); err != nil {
-		return nil, fmt.Errorf("failed to parse auth info: %w", err)
-	}
-
-	if authInfo.Code != CodeSuccess {
-		return nil, fmt.Errorf("failed to get auth info, code: %d", authInfo.Code)
+	if err := c.api.Do(ctx, http.MethodPost, constants.AuthInfoPath, reqBody, &authInfo, api.Unauthenticated()); err != nil {
+		return nil, fromAPIError(err)
 	}
 
 	// Validate required fields
@@ -386,117 +431,43 @@ func (c *Client) getAuthInfo() (*api.AuthInfoResponse, error) {
 	return &authInfo, nil
 }
 
-func (c *Client) sendAuthRequest(authReq map[string]interface{}) (*api.Session, error) {
-	body, err := json.Marshal(authReq)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, c.config.APIURL+"/core/v4/auth", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-
-	c.setHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func (c *Client) sendAuthRequest(ctx context.Context, authReq map[string]interface{}, verification *HumanVerification) (*api.Session, error) {
+	// SRP proofs are bound to single-use ephemerals, so the request must never be replayed
+	opts := []api.RequestOption{
+		api.Unauthenticated(),
+		api.WithoutRetry(),
+		api.WithAllowedCodes(CodeMailboxPasswordError),
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("authentication HTTP error %d: %s", resp.StatusCode, string(respBody))
+	if verification != nil {
+		opts = append(opts,
+			api.WithHeader("x-pm-human-verification-token-type", verification.Type),
+			api.WithHeader("x-pm-human-verification-token", verification.Token),
+		)
 	}
 
 	var session api.Session
-	if err := json.Unmarshal(respBody, &session); err != nil {
-		return nil, err
+	if err := c.api.Do(ctx, http.MethodPost, constants.AuthPath, authReq, &session, opts...); err != nil {
+		return nil, fromAPIError(err)
 	}
 
-	// Handle mailbox password request (2-password mode)
-	// Code 10013 means the account uses legacy 2-password mode which requires a separate mailbox password
-	// VPN doesn't need mailbox decryption, but the auth flow requires completing it
-	if session.Code == CodeMailboxPasswordError {
-		return nil, fmt.Errorf("your account uses legacy 2-password mode which is not supported.\n" +
-			"Please switch to single-password mode:\n" +
-			"  1. Go to account.proton.me\n" +
-			"  2. Settings → All settings → Account and password → Passwords\n" +
-			"  3. Switch to 'One-password mode'\n" +
-			"This is recommended by Proton for most users and is required for this tool")
-	}
-
-	if session.Code != CodeSuccess {
+	// Code 10013 means the account uses legacy 2-password mode which requires a separate mailbox password.
+	// VPN doesn't need mailbox decryption, but the auth flow requires completing it (see unlockMailbox)
+	if session.Code == CodeMailboxPasswordError && session.AccessToken == "" {
 		return nil, NewError(session.Code)
 	}
 
 	return &session, nil
 }
 
-func (c *Client) setHeaders(req *http.Request) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-pm-appversion", constants.AppVersion)
-	req.Header.Set("User-Agent", constants.UserAgent)
-}
-
 // submit2FA submits a 2FA code to upgrade the session with additional scopes (like VPN)
-func (c *Client) submit2FA(session *api.Session, code string) ([]string, error) {
+func (c *Client) submit2FA(ctx context.Context, code string) ([]string, error) {
 	reqBody := map[string]interface{}{
 		"TwoFactorCode": code,
 	}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, c.config.APIURL+"/core/v4/auth/2fa", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-
-	// Need to include auth headers for 2FA upgrade
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", session.AccessToken))
-	req.Header.Set("x-pm-uid", session.UID)
-	req.Header.Set("x-pm-appversion", constants.AppVersion)
-	req.Header.Set("User-Agent", constants.UserAgent)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("2FA HTTP error %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	// Parse response to get updated scopes
-	var twoFAResp struct {
-		Code   int      `json:"Code"`
-		Scopes []string `json:"Scopes"`
-		Error  string   `json:"Error,omitempty"`
-	}
-	if err := json.Unmarshal(respBody, &twoFAResp); err != nil {
-		return nil, fmt.Errorf("failed to parse 2FA response: %w", err)
-	}
-
-	if twoFAResp.Code != CodeSuccess {
-		if twoFAResp.Error != "" {
-			return nil, fmt.Errorf("2FA failed (code %d): %s", twoFAResp.Code, twoFAResp.Error)
-		}
-		return nil, NewError(twoFAResp.Code)
+	var twoFAResp api.TwoFactorResponse
+	if err := c.api.Do(ctx, http.MethodPost, constants.TwoFactorPath, reqBody, &twoFAResp); err != nil {
+		return nil, fromAPIError(err)
 	}
 
 	return twoFAResp.Scopes, nil
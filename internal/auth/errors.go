@@ -3,8 +3,13 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 
+	"protonvpn-wg-config-generate/internal/api"
 	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
 )
 
 // Error codes from ProtonVPN API
@@ -30,6 +35,21 @@ func (e Error) Error() string {
 	return e.Message
 }
 
+// ErrorClass classifies the error by its code. Codes that don't mean the credentials were
+// rejected (e.g. rate limits or a rejected app version) are API errors.
+func (e Error) ErrorClass() errclass.Class {
+	switch e.Code {
+	case CodeCaptchaRequired:
+		return errclass.Captcha
+	case Code2FARequiredForVPN, Code2FARequired, CodeInvalid2FA:
+		return errclass.TwoFactor
+	case CodeWrongPassword, CodeWrongPasswordFormat, CodeMailboxPasswordError:
+		return errclass.AuthFailed
+	default:
+		return errclass.API
+	}
+}
+
 // NewError creates a new authentication error from an API response code
 func NewError(code int) error {
 	message := getErrorMessage(code)
@@ -39,6 +59,64 @@ func NewError(code int) error {
 	}
 }
 
+// fromAPIError converts an API error into an authentication error. Codes without a
+// dedicated message keep the message returned by the API.
+func fromAPIError(err error) error {
+	apiErr, ok := api.AsError(err)
+	if !ok || apiErr.Code == 0 {
+		return err
+	}
+
+	if apiErr.Code == CodeCaptchaRequired {
+		var details api.HumanVerificationDetails
+		if apiErr.DecodeDetails(&details) == nil {
+			return &HumanVerificationError{
+				Methods: details.HumanVerificationMethods,
+				Token:   details.HumanVerificationToken,
+			}
+		}
+	}
+
+	message := getErrorMessage(apiErr.Code)
+	if message == unknownErrorMessage(apiErr.Code) && apiErr.Message != "" {
+		message = fmt.Sprintf("%s (code %d)", apiErr.Message, apiErr.Code)
+	}
+
+	return Error{
+		Code:    apiErr.Code,
+		Message: message,
+	}
+}
+
+// HumanVerificationError is returned when the API requires human verification (CAPTCHA) to continue
+type HumanVerificationError struct {
+	Methods []string
+	Token   string
+}
+
+// Error implements the error interface
+func (e *HumanVerificationError) Error() string {
+	return fmt.Sprintf("%s (methods: %s)", getErrorMessage(CodeCaptchaRequired), strings.Join(e.Methods, ", "))
+}
+
+// ErrorClass implements errclass.Classifier
+func (e *HumanVerificationError) ErrorClass() errclass.Class {
+	return errclass.Captcha
+}
+
+// Supports reports whether the API offered the given verification method
+func (e *HumanVerificationError) Supports(method string) bool {
+	return slices.Contains(e.Methods, method)
+}
+
+// URL returns the address where the verification can be completed in a browser
+func (e *HumanVerificationError) URL() string {
+	query := url.Values{}
+	query.Set("methods", strings.Join(e.Methods, ","))
+	query.Set("token", e.Token)
+	return constants.HumanVerificationURL + "/?" + query.Encode()
+}
+
 // getErrorMessage returns a human-readable error message for a given error code
 func getErrorMessage(code int) string {
 	switch code {
@@ -55,12 +133,17 @@ func getErrorMessage(code int) string {
 	case CodeInvalid2FA:
 		return "invalid 2FA code"
 	case CodeMailboxPasswordError:
-		return "account uses legacy 2-password mode - please switch to single-password mode at account.proton.me"
+		return "account uses legacy 2-password mode and the mailbox password step could not be completed"
 	default:
-		return fmt.Sprintf("authentication failed with code: %d", code)
+		return unknownErrorMessage(code)
 	}
 }
 
+// unknownErrorMessage returns the message for codes without a dedicated one
+func unknownErrorMessage(code int) string {
+	return fmt.Sprintf("authentication failed with code: %d", code)
+}
+
 // Is2FAError checks if the error is a 2FA-related error
 func Is2FAError(err error) bool {
 	var authErr Error
@@ -72,6 +155,11 @@ func Is2FAError(err error) bool {
 
 // IsCaptchaError checks if the error requires CAPTCHA verification
 func IsCaptchaError(err error) bool {
+	var hvErr *HumanVerificationError
+	if errors.As(err, &hvErr) {
+		return true
+	}
+
 	var authErr Error
 	if !errors.As(err, &authErr) {
 		return false
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		code int
+		want errclass.Class
+	}{
+		{CodeWrongPassword, errclass.AuthFailed},
+		{CodeWrongPasswordFormat, errclass.AuthFailed},
+		{CodeMailboxPasswordError, errclass.AuthFailed},
+		{CodeCaptchaRequired, errclass.Captcha},
+		{Code2FARequiredForVPN, errclass.TwoFactor},
+		{Code2FARequired, errclass.TwoFactor},
+		{CodeInvalid2FA, errclass.TwoFactor},
+		{constants.APICodeAppVersionBad, errclass.API},
+		{2028, errclass.API},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("authentication failed: %w", NewError(tt.code))
+		if got := errclass.Of(err); got != tt.want {
+			t.Errorf("code %d: class = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/internal/prompt"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-srp"
+)
+
+// keyPassphraseLength is the length of the salted key passphrase derived from the mailbox password
+const keyPassphraseLength = 31
+
+// needsMailboxUnlock reports whether the session belongs to a legacy 2-password mode account
+func needsMailboxUnlock(session *api.Session) bool {
+	return session.PasswordMode == api.PasswordModeTwo || session.Code == CodeMailboxPasswordError
+}
+
+// unlockMailbox completes the second step of 2-password mode authentication.
+// The mailbox password is verified against the user's primary key, after which
+// the session scopes are reloaded so the VPN scope becomes visible.
+func (c *Client) unlockMailbox(ctx context.Context, session *api.Session) error {
+	if session.AccessToken == "" || session.UID == "" {
+		return NewError(CodeMailboxPasswordError)
+	}
+
+	slog.Info("Account uses 2-password mode, mailbox password required to unlock the session")
+	if err := c.ensureMailboxPassword(ctx); err != nil {
+		return err
+	}
+
+	passphrase, armoredKey, err := c.deriveKeyPassphrase(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyKeyPassphrase(armoredKey, passphrase); err != nil {
+		return err
+	}
+
+	var scopesResp api.ScopesResponse
+	if err := c.api.Do(ctx, http.MethodGet, constants.ScopesPath, nil, &scopesResp); err != nil {
+		return fmt.Errorf("failed to reload session scopes: %w", fromAPIError(err))
+	}
+
+	session.Scopes = scopesResp.Scopes
+	session.Code = CodeSuccess
+	slog.Info("Mailbox unlocked")
+	return nil
+}
+
+// deriveKeyPassphrase derives the key passphrase for the user's primary key
+func (c *Client) deriveKeyPassphrase(ctx context.Context) (passphrase []byte, armoredKey string, err error) {
+	var userResp api.UserResponse
+	if err := c.api.Do(ctx, http.MethodGet, constants.UsersPath, nil, &userResp); err != nil {
+		return nil, "", fmt.Errorf("failed to get user keys: %w", fromAPIError(err))
+	}
+
+	var saltsResp api.KeySaltsResponse
+	if err := c.api.Do(ctx, http.MethodGet, constants.KeySaltsPath, nil, &saltsResp); err != nil {
+		return nil, "", fmt.Errorf("failed to get key salts: %w", fromAPIError(err))
+	}
+
+	for _, key := range userResp.User.Keys {
+		if key.Primary != constants.EnabledTrue {
+			continue
+		}
+		for _, salt := range saltsResp.KeySalts {
+			if salt.ID != key.ID {
+				continue
+			}
+			saltBytes, err := base64.StdEncoding.DecodeString(salt.KeySalt)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid key salt: %w", err)
+			}
+			hashed, err := srp.MailboxPassword([]byte(c.config.MailboxPassword), saltBytes)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to hash mailbox password: %w", err)
+			}
+			return hashed[len(hashed)-keyPassphraseLength:], key.PrivateKey, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("no salt found for primary user key")
+}
+
+// verifyKeyPassphrase checks that the passphrase decrypts the armored private key
+func verifyKeyPassphrase(armoredKey string, passphrase []byte) error {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return fmt.Errorf("failed to read user key: %w", err)
+	}
+
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+			return errclass.New(errclass.AuthFailed, "incorrect mailbox password")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("user key has no private part")
+}
+
+func (c *Client) ensureMailboxPassword(ctx context.Context) error {
+	if c.config.MailboxPassword == "" {
+		password, err := prompt.ReadPassword(ctx, "Mailbox password: ")
+		if err != nil {
+			return fmt.Errorf("error reading mailbox password: %w", err)
+		}
+		c.config.MailboxPassword = password
+	}
+	if c.config.MailboxPassword == "" {
+		return errclass.New(errclass.InvalidInput, "mailbox password cannot be empty")
+	}
+	return nil
+}
@@ -1,10 +1,11 @@
 package auth
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 
 	"protonvpn-wg-config-generate/internal/api"
 	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/pkg/fileutil"
 )
 
 // SessionStore handles persistent session storage
@@ -69,7 +71,7 @@ func (s *SessionStore) Save(session *api.Session, username string, duration time
 		return fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	err = os.WriteFile(s.filePath, data, constants.SessionFileMode)
+	err = fileutil.WriteFileAtomic(s.filePath, data, constants.SessionFileMode)
 	if err != nil {
 		return fmt.Errorf("failed to write session file: %w", err)
 	}
@@ -112,6 +114,24 @@ func (s *SessionStore) Load(username string) (*api.Session, time.Duration, error
 	return savedSession.Session, timeUntilExpiry, nil
 }
 
+// Info returns the saved session with its metadata, or nil if there is none. Unlike Load, it
+// doesn't check the user or expiry.
+func (s *SessionStore) Info() (*SavedSession, error) {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var savedSession SavedSession
+	if err := json.Unmarshal(data, &savedSession); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &savedSession, nil
+}
+
 // Delete removes the saved session
 func (s *SessionStore) Delete() error {
 	err := os.Remove(s.filePath)
@@ -126,85 +146,47 @@ func (s *SessionStore) GetPath() string {
 	return s.filePath
 }
 
-// RefreshSession attempts to refresh the session using the refresh token.
-// It returns a new session with updated tokens if successful.
-func RefreshSession(httpClient *http.Client, apiURL string, oldSession *api.Session) (*api.Session, error) {
-	// Based on WebClients source, the refresh endpoint is /auth/refresh
-	reqBody := map[string]interface{}{
-		"ResponseType": "token",
-		"GrantType":    "refresh_token",
-		"RefreshToken": oldSession.RefreshToken,
-		"RedirectURI":  "https://protonmail.com",
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
+// Logout revokes the saved session on the server and deletes it. It returns the deleted session,
+// or nil if there was none. An expired access token is refreshed first, and a session the API no
+// longer accepts is still deleted.
+func (c *Client) Logout(ctx context.Context) (*SavedSession, error) {
+	savedSession, err := c.sessionStore.Info()
+	if err != nil || savedSession == nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, apiURL+"/auth/refresh", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-
-	// Set standard headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-pm-appversion", constants.AppVersion)
-	req.Header.Set("User-Agent", constants.UserAgent)
-
-	// Include auth headers for refresh
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", oldSession.AccessToken))
-	req.Header.Set("x-pm-uid", oldSession.UID)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	if savedSession.Session != nil {
+		// The session file is deleted below, so a refreshed session isn't saved
+		c.api.OnRefresh(nil)
+		c.api.SetSession(savedSession.Session)
+		if err := c.api.Do(ctx, http.MethodDelete, constants.AuthPath, nil, nil); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil, err
+			}
+			if sessionRejected(err) {
+				slog.Debug("Session was already invalid", "error", err)
+			} else {
+				slog.Warn("Failed to revoke session", "error", err)
+			}
+		}
+		c.api.SetSession(nil)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
+	if err := c.sessionStore.Delete(); err != nil {
 		return nil, err
 	}
-
-	if resp.StatusCode == http.StatusOK {
-		var session api.Session
-		if err := json.Unmarshal(respBody, &session); err != nil {
-			return nil, err
-		}
-		if session.Code == constants.APICodeSuccess {
-			return &session, nil
-		}
-	}
-
-	// If refresh fails, return error to trigger re-authentication
-	return nil, fmt.Errorf("refresh failed (status %d): %s", resp.StatusCode, string(respBody))
+	return savedSession, nil
 }
 
-// VerifySession checks if a session is still valid by making a test API request.
-func VerifySession(httpClient *http.Client, apiURL string, session *api.Session) bool {
-	// Make a simple request to verify the session
-	req, err := http.NewRequest(http.MethodGet, apiURL+"/vpn/v1/logicals", http.NoBody)
-	if err != nil {
-		return false
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", session.AccessToken))
-	req.Header.Set("x-pm-uid", session.UID)
-	req.Header.Set("x-pm-appversion", constants.AppVersion)
-	req.Header.Set("User-Agent", constants.UserAgent)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return false
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	// If we get a 401, the session is invalid
-	if resp.StatusCode == http.StatusUnauthorized {
-		return false
-	}
+// sessionRejected reports whether err means the API no longer accepts the session (or its
+// refresh token), so there is nothing left to revoke
+func sessionRejected(err error) bool {
+	apiErr, ok := api.AsError(err)
+	return ok && apiErr.Status >= 400 && apiErr.Status < 500 && apiErr.Status != http.StatusTooManyRequests
+}
 
-	// Any 2xx response means the session is valid
-	return resp.StatusCode >= 200 && resp.StatusCode < 300
+// VerifySession checks if the API client's session is still valid by making a test API request.
+// An expired access token is refreshed transparently by the client.
+func VerifySession(ctx context.Context, client *api.Client) bool {
+	return client.Do(ctx, http.MethodGet, constants.LogicalsPath, nil, nil) == nil
 }
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+func TestLogoutRefreshesExpiredSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var revoked bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == constants.RefreshPath:
+			_, _ = w.Write([]byte(`{"Code":1000,"AccessToken":"new","RefreshToken":"new-refresh","UID":"uid"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == constants.AuthPath && r.Header.Get("Authorization") == "Bearer new":
+			revoked = true
+			_, _ = w.Write([]byte(`{"Code":1000}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"Code":401,"Error":"Invalid access token"}`))
+		}
+	}))
+	defer server.Close()
+
+	// A session without a lifetime is saved as already expired
+	store := NewSessionStore()
+	if err := store.Save(&api.Session{AccessToken: "old", RefreshToken: "refresh", UID: "uid"}, "alice", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	savedSession, err := NewClient(&config.Config{APIURL: server.URL}).Logout(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if savedSession == nil || savedSession.Username != "alice" {
+		t.Errorf("Expected the session of alice to be returned, got %+v", savedSession)
+	}
+	if !revoked {
+		t.Error("Expected the session to be revoked with the refreshed token")
+	}
+	if _, err := os.Stat(store.GetPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected the session file to be deleted, got %v", err)
+	}
+}
+
+func TestDryRunDoesNotRefreshOrSaveSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		requests[r.URL.Path]++
+		_, _ = w.Write([]byte(`{"Code":1000,"Scopes":["full","vpn"]}`))
+	}))
+	defer server.Close()
+
+	// The session expires soon, which makes a real run refresh it
+	store := NewSessionStore()
+	if err := store.Save(&api.Session{AccessToken: "token", RefreshToken: "refresh", UID: "uid", ExpiresIn: 3600}, "alice", 0); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(store.GetPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{APIURL: server.URL, Username: "alice", DryRun: true}
+	session, err := NewClient(cfg).Authenticate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.AccessToken != "token" {
+		t.Errorf("Expected the saved session to be reused, got access token %q", session.AccessToken)
+	}
+	if requests[constants.RefreshPath] > 0 {
+		t.Error("Expected a dry run not to refresh the session")
+	}
+	if requests[constants.LogicalsPath] > 0 || requests[constants.ScopesPath] != 1 {
+		t.Errorf("Expected the session to be checked with the scopes endpoint only, got %v", requests)
+	}
+	after, err := os.ReadFile(store.GetPath())
+	if err != nil || !bytes.Equal(before, after) {
+		t.Errorf("Expected a dry run to leave the session file unchanged (%v)", err)
+	}
+}
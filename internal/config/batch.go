@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// batchManifest is a YAML batch manifest. Each device maps generate option names to values:
+//
+//	devices:
+//	  - device-name: office-1
+//	    countries: [NL]
+//	    output: office-1.conf
+type batchManifest struct {
+	Devices []map[string]yaml.Node `yaml:"devices"`
+}
+
+// batchOnlyFlags are the batch flags that don't apply to devices
+var batchOnlyFlags = map[string]bool{
+	"concurrency": true,
+	"report":      true,
+	"resume":      true,
+}
+
+// newBatchFlagSet creates the flag set for the batch subcommand. The generate flags set the
+// defaults for all devices of the manifest.
+func newBatchFlagSet(cfg *Config, raw *generateFlags) *flag.FlagSet {
+	fs := newGenerateFlagSet(cfg, raw)
+
+	fs.IntVar(&cfg.Concurrency, "concurrency", constants.DefaultBatchConcurrency, "Number of devices to generate at the same time")
+	fs.StringVar(&cfg.ReportFile, "report", "", "JSON report of the results (default <manifest>.report.json)")
+	fs.BoolVar(&cfg.Resume, "resume", false, "Skip the devices the report lists as successful")
+
+	return fs
+}
+
+// ParseBatch parses the arguments of the batch subcommand: batch [options] <manifest>. It returns
+// the batch configuration and the configuration of every device in the manifest.
+func ParseBatch(args []string) (*Config, []*Config, error) {
+	cfg := &Config{}
+	raw := &generateFlags{}
+
+	fs := newBatchFlagSet(cfg, raw)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() != 1 {
+		return nil, nil, fmt.Errorf("batch requires exactly one manifest file")
+	}
+	cfg.BatchManifest = fs.Arg(0)
+
+	if err := finalizeAuth(cfg); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Concurrency < 1 {
+		return nil, nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	if cfg.ReportFile == "" {
+		cfg.ReportFile = strings.TrimSuffix(cfg.BatchManifest, filepath.Ext(cfg.BatchManifest)) + ".report.json"
+	}
+
+	rows, err := readManifest(cfg.BatchManifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("no devices in %s", cfg.BatchManifest)
+	}
+
+	devices := make([]*Config, 0, len(rows))
+	outputs := make(map[string]int)
+	names := make(map[string]int)
+	for i, row := range rows {
+		device, err := parseBatchDevice(fs, row)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: device %d: %w", cfg.BatchManifest, i+1, err)
+		}
+
+		// The output file identifies a device in the report
+		if previous, ok := outputs[device.OutputFile]; ok {
+			return nil, nil, fmt.Errorf("%s: devices %d and %d both write %s", cfg.BatchManifest, previous, i+1, device.OutputFile)
+		}
+		outputs[device.OutputFile] = i + 1
+
+		// Auto-generated names of devices generated in the same second would be the same, and the
+		// key store keeps one key per name
+		if device.DeviceName == "" {
+			return nil, nil, fmt.Errorf("%s: device %d: device-name is required", cfg.BatchManifest, i+1)
+		}
+		if previous, ok := names[device.DeviceName]; ok && !strings.Contains(device.DeviceName, "{{") {
+			return nil, nil, fmt.Errorf("%s: devices %d and %d are both named %s", cfg.BatchManifest, previous, i+1, device.DeviceName)
+		}
+		names[device.DeviceName] = i + 1
+
+		devices = append(devices, device)
+	}
+
+	return cfg, devices, nil
+}
+
+// parseBatchDevice creates the configuration of one device from the batch flags, overridden by
+// the values of the manifest row
+func parseBatchDevice(batchFS *flag.FlagSet, row map[string]string) (*Config, error) {
+	cfg := &Config{}
+	raw := &generateFlags{}
+	fs := newGenerateFlagSet(cfg, raw)
+
+	// Copy the effective batch values without marking them as set, so a device can switch
+	// between -duration and -valid-until
+	var err error
+	batchFS.VisitAll(func(f *flag.Flag) {
+		if target := fs.Lookup(f.Name); target != nil && err == nil {
+			err = target.Value.Set(f.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Authentication and API options apply to the whole batch
+	shared := newFlagSet("shared")
+	registerAuthFlags(shared, &Config{})
+	registerKeyStoreFlags(shared, &Config{})
+
+	for name, value := range row {
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown option %s", name)
+		}
+		if batchOnlyFlags[name] || shared.Lookup(name) != nil {
+			return nil, fmt.Errorf("%s applies to the whole batch and can't be set per device", name)
+		}
+		if conflict, ok := conflictingFlags[name]; ok {
+			if err := fs.Lookup(conflict).Value.Set(fs.Lookup(conflict).DefValue); err != nil {
+				return nil, err
+			}
+		}
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+	}
+
+	if err := finalizeGenerate(fs, cfg, raw, true); err != nil {
+		return nil, err
+	}
+	if cfg.Interactive || cfg.DryRun {
+		return nil, fmt.Errorf("-interactive and -dry-run can't be used with batch")
+	}
+	return cfg, nil
+}
+
+// readManifest reads the devices of a CSV manifest (with a header row of option names) or a
+// YAML manifest. Empty CSV cells keep the batch value.
+func readManifest(path string) ([]map[string]string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // reading the user-specified manifest is intended
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCSVManifest(string(data))
+	}
+
+	var manifest batchManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	rows := make([]map[string]string, 0, len(manifest.Devices))
+	for i, device := range manifest.Devices {
+		row := make(map[string]string, len(device))
+		for name, node := range device {
+			value, err := formatValue(&node)
+			if err != nil {
+				return nil, fmt.Errorf("%s: device %d: %s: %w", path, i+1, name, err)
+			}
+			row[name] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readCSVManifest parses a CSV manifest
+func readCSVManifest(data string) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if value := strings.TrimSpace(record[i]); value != "" {
+				row[strings.TrimSpace(name)] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// PrintBatchUsage prints usage information for the batch subcommand
+func PrintBatchUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s batch [options] <manifest.yaml|manifest.csv>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Generates a config for every device of the manifest, authenticating and fetching servers once.\n")
+	fmt.Fprintf(os.Stderr, "Devices set generate options (e.g. device-name, countries, output); the flags below are their defaults.\n\n")
+	printDefaults(newBatchFlagSet(&Config{}, &generateFlags{}))
+}
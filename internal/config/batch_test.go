@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeManifest(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestParseBatch(t *testing.T) {
+	csvManifest := writeManifest(t, "office.csv", `# office rollout
+device-name,countries,output,valid-until
+office-1,NL,office-1.conf,
+office-2,"NL,BE",office-2.conf,2027-01-01
+`)
+	yamlManifest := writeManifest(t, "office.yaml", `
+devices:
+  - device-name: office-1
+    output: office-1.conf
+  - device-name: office-2
+    countries: [NL, BE]
+    output: office-2.conf
+    valid-until: 2027-01-01
+`)
+
+	for _, manifest := range []string{csvManifest, yamlManifest} {
+		t.Run(filepath.Ext(manifest), func(t *testing.T) {
+			cfg, devices, err := ParseBatch([]string{"-countries", "NL", "-duration", "30d", "-netshield", "1", "-concurrency", "2", manifest})
+			if err != nil {
+				t.Fatalf("ParseBatch failed: %v", err)
+			}
+			if cfg.Concurrency != 2 || cfg.ReportFile != manifest[:len(manifest)-len(filepath.Ext(manifest))]+".report.json" {
+				t.Errorf("Got concurrency %d, report %s", cfg.Concurrency, cfg.ReportFile)
+			}
+			if len(devices) != 2 {
+				t.Fatalf("Expected 2 devices, got %d", len(devices))
+			}
+
+			// Batch flags are the defaults of every device
+			first, second := devices[0], devices[1]
+			if first.DeviceName != "office-1" || len(first.Countries) != 1 || first.Duration != "30d" || first.NetShieldLevel != 1 {
+				t.Errorf("Unexpected first device: %+v", first)
+			}
+			// A device's valid-until replaces the batch duration
+			if second.OutputFile != "office-2.conf" || len(second.Countries) != 2 || second.ValidUntil != "2027-01-01" || second.NetShieldLevel != 1 {
+				t.Errorf("Unexpected second device: %+v", second)
+			}
+		})
+	}
+}
+
+func TestParseBatchErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"duplicate output", "m.csv", "device-name,output\na,x.conf\nb,x.conf\n"},
+		{"duplicate name", "m.csv", "device-name,output\na,a.conf\na,b.conf\n"},
+		{"missing name", "m.csv", "output\na.conf\n"},
+		{"unknown option", "m.yaml", "devices:\n  - device-name: a\n    colour: blue\n"},
+		{"shared option", "m.yaml", "devices:\n  - device-name: a\n    username: bob\n"},
+		{"invalid value", "m.yaml", "devices:\n  - device-name: a\n    netshield: 7\n"},
+		{"no devices", "m.yaml", "devices: []\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := writeManifest(t, tt.file, tt.content)
+			if _, _, err := ParseBatch([]string{"-countries", "NL", manifest}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
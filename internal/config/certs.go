@@ -0,0 +1,136 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"protonvpn-wg-config-generate/pkg/timeutil"
+)
+
+// Certificate management actions
+const (
+	CertsActionList   = "list"
+	CertsActionRevoke = "revoke"
+	CertsActionUpdate = "update"
+)
+
+// FeatureUpdate holds the certificate features given for certs update. Nil fields keep the
+// certificate's current value.
+type FeatureUpdate struct {
+	NetShieldLevel *int
+	VPNAccelerator *bool
+	ModerateNAT    *bool
+	PortForwarding *bool
+}
+
+// newCertsFlagSet creates the flag set for the certs subcommand
+func newCertsFlagSet(cfg *Config) *flag.FlagSet {
+	fs := newFlagSet("certs")
+	registerAuthFlags(fs, cfg)
+	registerKeyStoreFlags(fs, cfg)
+
+	fs.StringVar(&cfg.OlderThan, "older-than", "", "Revoke certificates created longer ago than this (e.g., 30d, 12h)")
+	fs.BoolVar(&cfg.AssumeYes, "yes", false, "Revoke without asking for confirmation")
+
+	// Only used by update
+	registerCertificateFlags(fs, cfg)
+
+	return fs
+}
+
+// ParseCerts parses the arguments of the certs subcommand: certs <list|revoke|update> [options] [targets]
+func ParseCerts(args []string) (*Config, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("certs requires an action: list, revoke or update")
+	}
+
+	cfg := &Config{CertsAction: args[0]}
+	fs := newCertsFlagSet(cfg)
+	if _, err := parseFlags(fs, cfg, args[1:]); err != nil {
+		return nil, err
+	}
+
+	if err := finalizeAuth(cfg); err != nil {
+		return nil, err
+	}
+
+	// Flags after a target would be taken as targets, e.g. -yes as a glob
+	cfg.CertsTargets = fs.Args()
+	for _, target := range cfg.CertsTargets {
+		if strings.HasPrefix(target, "-") {
+			return nil, fmt.Errorf("options must come before the targets: %s", target)
+		}
+		if _, err := path.Match(target, ""); err != nil {
+			return nil, fmt.Errorf("invalid device-name glob: %s", target)
+		}
+	}
+
+	switch cfg.CertsAction {
+	case CertsActionList:
+		if len(cfg.CertsTargets) > 0 || cfg.OlderThan != "" {
+			return nil, fmt.Errorf("certs list does not accept targets or -older-than")
+		}
+	case CertsActionRevoke:
+		if len(cfg.CertsTargets) == 0 && cfg.OlderThan == "" {
+			return nil, fmt.Errorf("certs revoke requires a serial, a device-name glob or -older-than")
+		}
+		if cfg.OlderThan != "" {
+			// A non-positive age would match every certificate
+			if age, err := timeutil.ParseDuration(cfg.OlderThan); err != nil || age <= 0 {
+				return nil, fmt.Errorf("invalid -older-than value: %s (expected a positive duration)", cfg.OlderThan)
+			}
+		}
+	case CertsActionUpdate:
+		if err := finalizeCertsUpdate(fs, cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown certs action: %s (expected list, revoke or update)", cfg.CertsAction)
+	}
+
+	return cfg, nil
+}
+
+// finalizeCertsUpdate validates the update targets and records which features were given
+func finalizeCertsUpdate(fs *flag.FlagSet, cfg *Config) error {
+	if len(cfg.CertsTargets) == 0 {
+		return fmt.Errorf("certs update requires the device name (or glob) of a stored key")
+	}
+	if err := validateCertificateFlags(fs, cfg); err != nil {
+		return err
+	}
+
+	if isFlagSet(fs, "netshield") {
+		cfg.Features.NetShieldLevel = &cfg.NetShieldLevel
+	}
+	if isFlagSet(fs, "accelerator") {
+		cfg.Features.VPNAccelerator = &cfg.EnableAccelerator
+	}
+	if isFlagSet(fs, "moderate-nat") {
+		cfg.Features.ModerateNAT = &cfg.ModerateNAT
+	}
+	if isFlagSet(fs, "port-forwarding") {
+		cfg.Features.PortForwarding = &cfg.PortForwarding
+	}
+
+	// Without a new lifetime on the command line the current expiry is kept
+	if !isFlagSet(fs, "duration") && !isFlagSet(fs, "valid-until") {
+		cfg.Duration, cfg.ValidUntil = "", ""
+	}
+
+	if cfg.Features == (FeatureUpdate{}) && cfg.Duration == "" && cfg.ValidUntil == "" {
+		return fmt.Errorf("certs update requires a feature (-netshield, -accelerator, -moderate-nat, -port-forwarding) or a new -duration/-valid-until")
+	}
+	return nil
+}
+
+// PrintCertsUsage prints usage information for the certs subcommand
+func PrintCertsUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s certs list [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s certs revoke [options] [serial|device-name-glob...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s certs update [options] <device-name-glob...>\n\n", os.Args[0])
+	printDefaults(newCertsFlagSet(&Config{}))
+}
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestParseCertsTargets(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := ParseCerts([]string{"revoke", "-yes", "laptop-*", "1001"})
+	if err != nil {
+		t.Fatalf("ParseCerts failed: %v", err)
+	}
+	if !cfg.AssumeYes || len(cfg.CertsTargets) != 2 {
+		t.Errorf("Unexpected config: yes=%v targets=%v", cfg.AssumeYes, cfg.CertsTargets)
+	}
+
+	invalid := [][]string{
+		{"revoke", "laptop", "-yes"},
+		{"revoke", "laptop-[0-9"},
+		{"update", "-netshield", "2", "office-["},
+		{"revoke", "-older-than", "-1h"},
+		{"revoke", "-older-than", "0s"},
+	}
+	for _, args := range invalid {
+		if _, err := ParseCerts(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
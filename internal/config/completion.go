@@ -0,0 +1,128 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Completion shells
+const (
+	ShellBash = "bash"
+	ShellZsh  = "zsh"
+	ShellFish = "fish"
+)
+
+// Completion value kinds, completed from the server cache
+const (
+	ValuesCountries = "countries"
+	ValuesCities    = "cities"
+	ValuesServers   = "servers"
+)
+
+// completionValuesAction prints completion values; the generated scripts call it
+const completionValuesAction = "values"
+
+// Flag describes a command-line flag for shell completion
+type Flag struct {
+	Name  string
+	Usage string
+	Bool  bool
+}
+
+// commandFlagSet creates the flag set of a command, or returns nil for commands without flags
+func commandFlagSet(command string) *flag.FlagSet {
+	var warn string
+	switch command {
+	case "generate", "config":
+		return newGenerateFlagSet(&Config{}, &generateFlags{})
+	case "renew":
+		return newRenewFlagSet(&Config{}, &generateFlags{})
+	case "batch":
+		return newBatchFlagSet(&Config{}, &generateFlags{})
+	case "servers":
+		return newServersFlagSet(&Config{}, &generateFlags{})
+	case "certs":
+		return newCertsFlagSet(&Config{})
+	case "keys":
+		return newKeysFlagSet(&Config{})
+	case "status":
+		return newStatusFlagSet(&Config{}, &warn)
+	case "port-forward":
+		return newPortForwardFlagSet(&Config{})
+	case "login":
+		return newLoginFlagSet(&Config{})
+	case "logout":
+		return newLogoutFlagSet(&Config{})
+	case "doctor":
+		return newDoctorFlagSet(&Config{})
+	default:
+		return nil
+	}
+}
+
+// CommandFlags returns the flags of a command sorted by name, including the config file flags
+func CommandFlags(command string) []Flag {
+	fs := commandFlagSet(command)
+	if fs == nil {
+		return nil
+	}
+	registerConfigFileFlags(fs, &Config{})
+
+	var flags []Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, Flag{Name: f.Name, Usage: f.Usage, Bool: ok && boolFlag.IsBoolFlag()})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// ParseCompletion parses the arguments of the completion subcommand: completion <bash|zsh|fish>,
+// or completion values <countries|cities|servers> [word] as called by the completion scripts
+func ParseCompletion(args []string) (*Config, error) {
+	fs := newFlagSet("completion")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	args = fs.Args()
+
+	cfg := &Config{}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("completion requires a shell: bash, zsh or fish")
+	}
+	if args[0] == completionValuesAction {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("completion values requires a kind and an optional word")
+		}
+		switch args[1] {
+		case ValuesCountries, ValuesCities, ValuesServers:
+		default:
+			return nil, fmt.Errorf("unknown completion values: %s (expected countries, cities or servers)", args[1])
+		}
+		cfg.CompletionValues = args[1]
+		if len(args) == 3 {
+			cfg.CompletionWord = args[2]
+		}
+		return cfg, nil
+	}
+
+	if len(args) > 1 {
+		return nil, fmt.Errorf("unexpected arguments: %v", args[1:])
+	}
+	switch args[0] {
+	case ShellBash, ShellZsh, ShellFish:
+	default:
+		return nil, fmt.Errorf("unsupported shell: %s (expected bash, zsh or fish)", args[0])
+	}
+	cfg.CompletionShell = args[0]
+	return cfg, nil
+}
+
+// PrintCompletionUsage prints usage information for the completion subcommand
+func PrintCompletionUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s completion <bash|zsh|fish>\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Prints a completion script for the shell. Values for -countries, -cities and -servers\n")
+	fmt.Fprintf(os.Stderr, "are completed from the server list cached by the last command that fetched it.\n")
+}
@@ -0,0 +1,39 @@
+package config
+
+import "testing"
+
+func TestParseCompletion(t *testing.T) {
+	cfg, err := ParseCompletion([]string{ShellZsh})
+	if err != nil || cfg.CompletionShell != ShellZsh {
+		t.Fatalf("ParseCompletion(zsh) = %+v, %v", cfg, err)
+	}
+
+	cfg, err = ParseCompletion([]string{"values", ValuesCities, "Amsterdam,Z"})
+	if err != nil || cfg.CompletionValues != ValuesCities || cfg.CompletionWord != "Amsterdam,Z" {
+		t.Fatalf("ParseCompletion(values) = %+v, %v", cfg, err)
+	}
+
+	for _, args := range [][]string{nil, {"powershell"}, {"bash", "zsh"}, {"values", "devices"}} {
+		if _, err := ParseCompletion(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	flags := make(map[string]Flag)
+	for _, f := range CommandFlags("servers") {
+		flags[f.Name] = f
+	}
+	for _, name := range []string{"countries", "cities", "servers", "profile"} {
+		if _, ok := flags[name]; !ok {
+			t.Errorf("Expected servers to have -%s", name)
+		}
+	}
+	if !flags["p2p-only"].Bool || flags["countries"].Bool {
+		t.Error("Expected only boolean flags to be marked as such")
+	}
+	if CommandFlags("session") != nil {
+		t.Error("Expected session to have no flags")
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Doctor report formats
+const (
+	DoctorFormatText = "text"
+	DoctorFormatJSON = "json"
+)
+
+// newDoctorFlagSet creates the flag set for the doctor subcommand
+func newDoctorFlagSet(cfg *Config) *flag.FlagSet {
+	fs := newFlagSet("doctor")
+	registerAPIFlags(fs, cfg)
+
+	fs.StringVar(&cfg.DoctorFormat, "format", DoctorFormatText, "Report format: text or json")
+
+	return fs
+}
+
+// ParseDoctor parses the arguments of the doctor subcommand
+func ParseDoctor(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	fs := newDoctorFlagSet(cfg)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if cfg.DoctorFormat != DoctorFormatText && cfg.DoctorFormat != DoctorFormatJSON {
+		return nil, fmt.Errorf("invalid -format value: %s (expected text or json)", cfg.DoctorFormat)
+	}
+
+	if err := finalizeAuth(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PrintDoctorUsage prints usage information for the doctor subcommand
+func PrintDoctorUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s doctor [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Checks the saved session, API access and the clock, with hints for problems found.\n\n")
+	printDefaults(newDoctorFlagSet(&Config{}))
+}
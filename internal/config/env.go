@@ -0,0 +1,49 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables that set flags, e.g. PROTONVPN_WG_COUNTRIES
+const EnvPrefix = "PROTONVPN_WG_"
+
+// SourceEnv is the source of flag values set by environment variables
+const SourceEnv = "environment"
+
+// EnvName returns the environment variable for a flag: the prefix followed by the flag name in
+// upper case with dashes replaced by underscores
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets the flags that weren't given on the command line from their environment
+// variables. Empty variables are ignored. Like file values, they don't count as set for isFlagSet.
+func applyEnv(fs *flag.FlagSet, sources map[string]string) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value := os.Getenv(EnvName(f.Name))
+		if err != nil || value == "" || sources[f.Name] != "" {
+			return
+		}
+
+		if conflict, ok := conflictingFlags[f.Name]; ok {
+			switch {
+			case sources[conflict] == SourceFlag:
+				return
+			case os.Getenv(EnvName(conflict)) != "":
+				err = fmt.Errorf("%s and %s cannot be combined", EnvName(f.Name), EnvName(conflict))
+				return
+			}
+		}
+
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, EnvName(f.Name), setErr)
+			return
+		}
+		sources[f.Name] = SourceEnv
+	})
+	return err
+}
@@ -0,0 +1,318 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// Sources of effective flag values, from highest to lowest precedence (SourceEnv comes second)
+const (
+	SourceFlag     = "flag"
+	SourceProfile  = "profile"
+	SourceDefaults = "config file"
+	SourceDefault  = "default"
+)
+
+// conflictingFlags are flags that can't be combined. A file value is dropped when its
+// counterpart is set with higher precedence.
+var conflictingFlags = map[string]string{
+	"duration":    "valid-until",
+	"valid-until": "duration",
+}
+
+// File is a config file with default flag values and named profiles. Keys are flag names
+// without the leading dash, e.g.:
+//
+//	defaults:
+//	  username: alice
+//	profiles:
+//	  office-nl:
+//	    countries: [NL]
+//	    netshield: 2
+type File struct {
+	Defaults map[string]yaml.Node            `yaml:"defaults"`
+	Profiles map[string]map[string]yaml.Node `yaml:"profiles"`
+}
+
+// setting is a flag value from the config file
+type setting struct {
+	value  string
+	source string
+}
+
+// DefaultConfigFilePath returns $XDG_CONFIG_HOME/protonvpn-wg/config.yaml, falling back to ~/.config
+func DefaultConfigFilePath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			homeDir = "."
+		}
+		dir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(dir, constants.ConfigDirName, constants.ConfigFileName)
+}
+
+// LoadFile reads and validates a config file
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // reading the user's config file is intended
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	known := knownFlags()
+	check := func(section string, values map[string]yaml.Node) error {
+		for name := range values {
+			if name == "config" || name == "profile" {
+				return fmt.Errorf("%s: %s: %s cannot be set in the config file", path, section, name)
+			}
+			if !known[name] {
+				return fmt.Errorf("%s: %s: unknown option %s", path, section, name)
+			}
+		}
+		return nil
+	}
+	if err := check("defaults", file.Defaults); err != nil {
+		return nil, err
+	}
+	for name, values := range file.Profiles {
+		if err := check("profile "+name, values); err != nil {
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+// settings merges the defaults with the named profile (if any)
+func (f *File) settings(profile string) (map[string]setting, error) {
+	merged := make(map[string]setting)
+	if err := mergeSettings(merged, f.Defaults, SourceDefaults); err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		return merged, nil
+	}
+	values, ok := f.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(f.Profiles))
+		for name := range f.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown profile: %s (available: %s)", profile, strings.Join(names, ", "))
+	}
+	if err := mergeSettings(merged, values, SourceProfile+" "+profile); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeSettings adds values to merged, overriding earlier values and their conflicting flags
+func mergeSettings(merged map[string]setting, values map[string]yaml.Node, source string) error {
+	for name, node := range values {
+		value, err := formatValue(&node)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", source, name, err)
+		}
+		if conflict, ok := conflictingFlags[name]; ok {
+			delete(merged, conflict)
+		}
+		merged[name] = setting{value: value, source: source}
+	}
+	return nil
+}
+
+// formatValue converts a YAML value to its flag syntax. Scalars are taken as written (so dates
+// and numbers aren't reformatted) and lists become comma-separated.
+func formatValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "", nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			s, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("line %d: expected a value or a list", node.Line)
+	}
+}
+
+// registerConfigFileFlags registers the flags that select the config file and profile
+func registerConfigFileFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.ConfigFile, "config", "", "Config file with defaults and profiles (default "+DefaultConfigFilePath()+")")
+	fs.StringVar(&cfg.Profile, "profile", "", "Profile from the config file to apply")
+}
+
+// parseFlags parses the command line and then applies the environment and the config file to the
+// flags that weren't given, in that order of precedence. It returns the source of every flag value
+// that didn't come from its default. Environment and file values don't count as set for isFlagSet.
+// Flag sets with logging flags also install the configured logger.
+func parseFlags(fs *flag.FlagSet, cfg *Config, args []string) (map[string]string, error) {
+	sources, err := parseFlagValues(fs, cfg, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure logging as soon as its flags are known, so every command logs the same way
+	if fs.Lookup("log-level") != nil {
+		if err := setupLogging(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// parseFlagValues parses the command line and applies the environment and config file values
+func parseFlagValues(fs *flag.FlagSet, cfg *Config, args []string) (map[string]string, error) {
+	registerConfigFileFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = SourceFlag
+	})
+	if err := applyEnv(fs, sources); err != nil {
+		return nil, err
+	}
+
+	path := cfg.ConfigFile
+	if path == "" {
+		path = DefaultConfigFilePath()
+	}
+	file, err := LoadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && cfg.ConfigFile == "" && cfg.Profile == "":
+		// The default config file is optional
+		return sources, nil
+	case err != nil:
+		return nil, err
+	}
+
+	settings, err := file.settings(cfg.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, s := range settings {
+		f := fs.Lookup(name)
+		if f == nil || sources[name] != "" || sources[conflictingFlags[name]] != "" {
+			continue
+		}
+		if err := f.Value.Set(s.value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s in %s: %w", s.value, name, s.source, err)
+		}
+		sources[name] = s.source
+	}
+	return sources, nil
+}
+
+// knownFlags returns the names of the flags of all commands
+func knownFlags() map[string]bool {
+	var warn string
+	flagSets := []*flag.FlagSet{
+		newRenewFlagSet(&Config{}, &generateFlags{}),
+		newCertsFlagSet(&Config{}),
+		newKeysFlagSet(&Config{}),
+		newStatusFlagSet(&Config{}, &warn),
+		newPortForwardFlagSet(&Config{}),
+		newServersFlagSet(&Config{}, &generateFlags{}),
+		newLoginFlagSet(&Config{}),
+		newLogoutFlagSet(&Config{}),
+		newDoctorFlagSet(&Config{}),
+	}
+
+	known := make(map[string]bool)
+	for _, fs := range flagSets {
+		fs.VisitAll(func(f *flag.Flag) {
+			known[f.Name] = true
+		})
+	}
+	return known
+}
+
+// secretFlags are flags whose values are not shown
+var secretFlags = map[string]bool{
+	"password":         true,
+	"mailbox-password": true,
+	"captcha-token":    true,
+}
+
+// Setting is the effective value of a flag and where it came from
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// ParseConfigShow parses the arguments of config show, which accepts the generate flags, and
+// returns the effective value of every flag with its source
+func ParseConfigShow(args []string) (*Config, []Setting, error) {
+	if len(args) == 0 || args[0] != "show" {
+		return nil, nil, fmt.Errorf("config requires an action: show")
+	}
+
+	cfg := &Config{}
+	raw := &generateFlags{}
+	fs := newGenerateFlagSet(cfg, raw)
+	sources, err := parseFlags(fs, cfg, args[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if err := finalizeGenerate(fs, cfg, raw, false); err != nil {
+		return nil, nil, err
+	}
+
+	var settings []Setting
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = "********"
+		}
+		source, ok := sources[f.Name]
+		if !ok {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Name: f.Name, Value: value, Source: source})
+	})
+	return cfg, settings, nil
+}
+
+// PrintConfigShowUsage prints usage information for the config subcommand
+func PrintConfigShowUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s config show [-config <file>] [-profile <name>] [generate options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Prints the effective generate options after merging the config file, profile and flags.\n\n")
+	printDefaults(newGenerateFlagSet(&Config{}, &generateFlags{}))
+}
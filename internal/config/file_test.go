@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfigFile = `
+defaults:
+  username: alice
+  duration: 30d
+  countries: [US]
+profiles:
+  office-nl:
+    countries: [NL, CH]
+    netshield: 2
+    valid-until: 2027-01-01
+`
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestParseConfigFilePrecedence(t *testing.T) {
+	path := writeConfigFile(t, testConfigFile)
+
+	tests := []struct {
+		name           string
+		args           []string
+		wantUsername   string
+		wantCountries  int
+		wantNetShield  int
+		wantDuration   string
+		wantValidUntil string
+	}{
+		{"defaults only", []string{"-config", path}, "alice", 1, 0, "30d", ""},
+		{"profile overrides defaults", []string{"-config", path, "-profile", "office-nl"}, "alice", 2, 2, "365d", "2027-01-01"},
+		{"flags override profile", []string{"-config", path, "-profile", "office-nl", "-username", "bob", "-netshield", "1", "-duration", "7d"}, "bob", 2, 1, "7d", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if cfg.Username != tt.wantUsername || len(cfg.Countries) != tt.wantCountries || cfg.NetShieldLevel != tt.wantNetShield {
+				t.Errorf("Got username %q, countries %v, netshield %d", cfg.Username, cfg.Countries, cfg.NetShieldLevel)
+			}
+			if cfg.Duration != tt.wantDuration || cfg.ValidUntil != tt.wantValidUntil {
+				t.Errorf("Got duration %q, valid-until %q", cfg.Duration, cfg.ValidUntil)
+			}
+		})
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		args    []string
+	}{
+		{"unknown option", "defaults:\n  colour: blue\n", nil},
+		{"unknown profile", testConfigFile, []string{"-profile", "home"}},
+		{"invalid value", "defaults:\n  netshield: high\n", nil},
+		{"nested value", "defaults:\n  countries:\n    nl: true\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-config", writeConfigFile(t, tt.content), "-countries", "NL"}, tt.args...)
+			if _, err := Parse(args); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := Parse([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml"), "-countries", "NL"}); err == nil {
+		t.Error("Expected an error for a missing -config file")
+	}
+}
+
+func TestParseEnvPrecedence(t *testing.T) {
+	path := writeConfigFile(t, testConfigFile)
+	t.Setenv("PROTONVPN_WG_PROFILE", "office-nl")
+	t.Setenv("PROTONVPN_WG_USERNAME", "carol")
+	t.Setenv("PROTONVPN_WG_DURATION", "14d")
+
+	cfg, err := Parse([]string{"-config", path, "-username", "bob"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// The flag beats the environment, which beats the profile (including its valid-until)
+	if cfg.Username != "bob" || cfg.Duration != "14d" || cfg.ValidUntil != "" || cfg.NetShieldLevel != 2 {
+		t.Errorf("Got username %q, duration %q, valid-until %q, netshield %d", cfg.Username, cfg.Duration, cfg.ValidUntil, cfg.NetShieldLevel)
+	}
+
+	t.Setenv("PROTONVPN_WG_NETSHIELD", "high")
+	if _, err := Parse([]string{"-config", path}); err == nil {
+		t.Error("Expected an error for an invalid environment value")
+	}
+}
@@ -4,71 +4,218 @@ package config
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/logging"
+	"protonvpn-wg-config-generate/pkg/timeutil"
 	"protonvpn-wg-config-generate/pkg/validation"
 )
 
-// Parse parses command-line flags and returns a Config
-func Parse() (*Config, error) {
-	cfg := &Config{}
+// Device name collision handling
+const (
+	CollisionRefuse = "refuse"
+	CollisionSuffix = "suffix"
+	CollisionRevoke = "revoke"
+)
 
-	var countriesFlag string
-	var dnsServersFlag string
-	var allowedIPsFlag string
+// generateFlags holds raw flag values that are post-processed into the Config
+type generateFlags struct {
+	countries  string
+	cities     string
+	servers    string
+	dnsServers string
+	allowedIPs string
+}
 
-	// Set default DNS and allowed IPs based on IPv6 support
-	defaultDNS := constants.DefaultDNSIPv4
-	defaultAllowedIPs := constants.DefaultAllowedIPsIPv4
+// newFlagSet creates a flag set that reports errors to the caller instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
 
+// registerAuthFlags registers the authentication and session flags shared by all commands
+func registerAuthFlags(fs *flag.FlagSet, cfg *Config) {
 	// Authentication flags
-	flag.StringVar(&cfg.Username, "username", "", "ProtonVPN username")
-	flag.StringVar(&cfg.Password, "password", "", "ProtonVPN password (will prompt if not provided)")
+	fs.StringVar(&cfg.Username, "username", "", "ProtonVPN username")
+	fs.StringVar(&cfg.Password, "password", "", "ProtonVPN password (will prompt if not provided)")
+	fs.StringVar(&cfg.MailboxPassword, "mailbox-password", "", "Mailbox password for legacy 2-password mode accounts (will prompt if required and not provided)")
+	fs.StringVar(&cfg.CaptchaToken, "captcha-token", "", "Token from a completed CAPTCHA verification (will prompt if required and not provided)")
+
+	// Session management
+	fs.BoolVar(&cfg.ClearSession, "clear-session", false, "Clear saved session and force re-authentication")
+	fs.BoolVar(&cfg.NoSession, "no-session", false, "Don't save or use session persistence")
+	fs.BoolVar(&cfg.ForceRefresh, "force-refresh", false, "Force session refresh even if not expired")
+	fs.StringVar(&cfg.SessionDuration, "session-duration", "0", "Session cache duration (e.g., 12h, 24h, 7d). 0 = no expiration")
+
+	registerAPIFlags(fs, cfg)
+}
+
+// registerAPIFlags registers the API client flags
+func registerAPIFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.APIURL, "api-url", constants.DefaultAPIURL, "ProtonVPN API URL")
+	fs.IntVar(&cfg.Retries, "retries", constants.DefaultAPIRetries, "Number of retries for transient API failures (5xx, 429, network errors)")
+	fs.DurationVar(&cfg.Timeout, "timeout", constants.DefaultAPITimeout, "Time limit for each API call, including retries (e.g., 30s, 2m)")
+
+	registerLogFlags(fs, cfg)
+}
+
+// registerLogFlags registers the logging flags
+func registerLogFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.Debug, "debug", false, "Enable debug output (same as -log-level debug)")
+	fs.StringVar(&cfg.LogLevel, "log-level", logging.LevelInfo, "Log level: debug, info, warn or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", logging.FormatText, "Log format on stderr: text or json")
+}
+
+// setupLogging installs the logger configured by the logging flags
+func setupLogging(cfg *Config) error {
+	level := cfg.LogLevel
+	if cfg.Debug {
+		level = logging.LevelDebug
+	}
+	return logging.Setup(os.Stderr, level, cfg.LogFormat)
+}
 
-	// Server selection flags
-	flag.StringVar(&countriesFlag, "countries", "", "Comma-separated list of country codes (e.g., US,NL,CH)")
-	flag.BoolVar(&cfg.P2PServersOnly, "p2p-only", constants.DefaultP2POnly, "Use only P2P-enabled servers")
-	flag.BoolVar(&cfg.SecureCoreOnly, "secure-core", false, "Use only Secure Core servers (multi-hop through privacy-friendly countries)")
-	flag.BoolVar(&cfg.FreeOnly, "free-only", false, "Use only Free tier servers (tier 0)")
+// registerKeyStoreFlags registers the key store flags shared by commands that create or use keys
+func registerKeyStoreFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.KeyStoreDir, "key-store", "", "Key store directory (default ~/"+constants.KeyStoreDirName+")")
+	fs.BoolVar(&cfg.NoKeyStore, "no-key-store", false, "Don't save or look up keys in the key store")
+}
+
+// finalizeAuth validates the shared flags and normalizes the username
+func finalizeAuth(cfg *Config) error {
+	// Validate API client settings
+	if cfg.Retries < 0 {
+		return fmt.Errorf("retries cannot be negative")
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	// Clean up username
+	cfg.Username = validation.CleanUsername(cfg.Username)
+	return nil
+}
+
+// newGenerateFlagSet creates the flag set for config generation
+func newGenerateFlagSet(cfg *Config, raw *generateFlags) *flag.FlagSet {
+	fs := newFlagSet("generate")
+	registerAuthFlags(fs, cfg)
+	registerKeyStoreFlags(fs, cfg)
+	registerServerFlags(fs, cfg, raw)
+	fs.BoolVar(&cfg.Interactive, "interactive", false, "Browse the servers in the terminal and pick one instead of selecting the best (-countries becomes optional)")
 
 	// Output configuration
-	flag.StringVar(&cfg.OutputFile, "output", "protonvpn.conf", "Output WireGuard configuration file")
-	flag.StringVar(&cfg.DeviceName, "device-name", "", "Device name for WireGuard config (auto-generated if empty)")
+	fs.StringVar(&cfg.OutputFile, "output", "protonvpn.conf", "Output WireGuard configuration file")
+	fs.StringVar(&cfg.DeviceName, "device-name", "", "Device name for WireGuard config, or a template such as {{.Hostname}}-{{.Country}}-{{.Date}} (auto-generated if empty; reuses the stored key of an existing device)")
+	fs.StringVar(&cfg.OnCollision, "on-collision", CollisionSuffix, "What to do when another device already uses the name: refuse, suffix (append -2, -3, ...) or revoke the other device")
+	fs.StringVar(&cfg.PublicKey, "public-key", "", "Use an Ed25519 public key generated on the device (PEM, base64 or a file); the config gets a private key placeholder")
+	fs.BoolVar(&cfg.PeerOnly, "peer-only", false, "Write only the [Peer] section, with the interface settings as comments")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Show the selected server and the config that would be written, without requesting a certificate or writing files")
 
 	// Network configuration
-	flag.BoolVar(&cfg.EnableIPv6, "ipv6", false, "Enable IPv6 support")
-	flag.StringVar(&dnsServersFlag, "dns", "", "Comma-separated list of DNS servers (defaults based on IPv6 setting)")
-	flag.StringVar(&allowedIPsFlag, "allowed-ips", "", "Comma-separated list of allowed IPs (defaults based on IPv6 setting)")
-	flag.BoolVar(&cfg.EnableAccelerator, "accelerator", true, "Enable VPN accelerator")
+	fs.BoolVar(&cfg.EnableIPv6, "ipv6", false, "Enable IPv6 support")
+	fs.StringVar(&raw.dnsServers, "dns", "", "Comma-separated list of DNS servers (defaults based on IPv6 setting)")
+	fs.StringVar(&raw.allowedIPs, "allowed-ips", "", "Comma-separated list of allowed IPs (defaults based on IPv6 setting)")
 
-	// Certificate configuration
-	flag.StringVar(&cfg.Duration, "duration", constants.DefaultCertDuration, "Certificate duration (e.g., 30m, 24h, 7d, 1h30m). Max: 365d")
+	registerCertificateFlags(fs, cfg)
 
-	// Session management
-	flag.BoolVar(&cfg.ClearSession, "clear-session", false, "Clear saved session and force re-authentication")
-	flag.BoolVar(&cfg.NoSession, "no-session", false, "Don't save or use session persistence")
-	flag.BoolVar(&cfg.ForceRefresh, "force-refresh", false, "Force session refresh even if not expired")
-	flag.StringVar(&cfg.SessionDuration, "session-duration", "0", "Session cache duration (e.g., 12h, 24h, 7d). 0 = no expiration")
+	return fs
+}
 
-	// Advanced configuration
-	flag.StringVar(&cfg.APIURL, "api-url", constants.DefaultAPIURL, "ProtonVPN API URL")
-	flag.BoolVar(&cfg.Debug, "debug", false, "Enable debug output")
+// registerServerFlags registers the server selection flags
+func registerServerFlags(fs *flag.FlagSet, cfg *Config, raw *generateFlags) {
+	fs.StringVar(&raw.countries, "countries", "", "Comma-separated list of country codes (e.g., US,NL,CH)")
+	fs.StringVar(&raw.cities, "cities", "", "Comma-separated list of cities to select from (e.g., Amsterdam,Zurich)")
+	fs.StringVar(&raw.servers, "servers", "", "Comma-separated list of server names to select from (e.g., NL#1,CH#12; -countries becomes optional)")
+	fs.BoolVar(&cfg.P2PServersOnly, "p2p-only", constants.DefaultP2POnly, "Use only P2P-enabled servers")
+	fs.BoolVar(&cfg.SecureCoreOnly, "secure-core", false, "Use only Secure Core servers (multi-hop through privacy-friendly countries)")
+	fs.BoolVar(&cfg.FreeOnly, "free-only", false, "Use only Free tier servers (tier 0)")
+}
 
-	flag.Parse()
+// registerCertificateFlags registers the certificate lifetime and feature flags
+func registerCertificateFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Duration, "duration", constants.DefaultCertDuration, "Certificate duration (e.g., 30m, 24h, 7d, 2w, 1h30m, P3M, P1Y). Max: 365d")
+	fs.StringVar(&cfg.ValidUntil, "valid-until", "", "Certificate expiry as a date (valid through that day) or RFC 3339 timestamp, instead of -duration")
+	fs.BoolVar(&cfg.EnableAccelerator, "accelerator", true, "Enable VPN accelerator")
+	fs.IntVar(&cfg.NetShieldLevel, "netshield", 0, "NetShield level: 0 (off), 1 (block malware), 2 (block malware, ads and trackers)")
+	fs.BoolVar(&cfg.ModerateNAT, "moderate-nat", false, "Enable moderate NAT (allows direct connections for gaming and calls)")
+	fs.BoolVar(&cfg.PortForwarding, "port-forwarding", false, "Enable port forwarding (requires a P2P server)")
+}
 
-	// Validate required flags
-	if countriesFlag == "" {
-		return nil, fmt.Errorf("countries flag is required")
+// validateCertificateFlags validates the certificate lifetime and features before authenticating
+func validateCertificateFlags(fs *flag.FlagSet, cfg *Config) error {
+	if cfg.ValidUntil != "" {
+		if isFlagSet(fs, "duration") {
+			return fmt.Errorf("-duration and -valid-until cannot be combined")
+		}
+		if _, err := timeutil.ValidUntilToMinutes(cfg.ValidUntil, time.Now()); err != nil {
+			return err
+		}
+	} else if _, err := timeutil.ParseToMinutes(cfg.Duration); err != nil {
+		return err
 	}
 
-	// Parse and validate country codes
-	cfg.Countries = parseCountries(countriesFlag)
-	for _, country := range cfg.Countries {
-		if !validation.IsValidCountryCode(country) {
-			return nil, fmt.Errorf("invalid country code: %s", country)
-		}
+	if cfg.NetShieldLevel < constants.NetShieldOff || cfg.NetShieldLevel > constants.NetShieldAdsTrackers {
+		return fmt.Errorf("invalid netshield level: %d (expected 0, 1 or 2)", cfg.NetShieldLevel)
+	}
+	return nil
+}
+
+// Parse parses the command-line arguments for config generation and returns a Config
+func Parse(args []string) (*Config, error) {
+	cfg := &Config{}
+	raw := &generateFlags{}
+
+	fs := newGenerateFlagSet(cfg, raw)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+
+	if err := finalizeGenerate(fs, cfg, raw, true); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// finalizeGenerate validates the generate flags and applies the defaults that depend on other flags
+func finalizeGenerate(fs *flag.FlagSet, cfg *Config, raw *generateFlags, requireCountries bool) error {
+	if err := finalizeAuth(cfg); err != nil {
+		return err
+	}
+
+	dnsServersFlag := raw.dnsServers
+	allowedIPsFlag := raw.allowedIPs
+
+	// Set default DNS and allowed IPs based on IPv6 support
+	defaultDNS := constants.DefaultDNSIPv4
+	defaultAllowedIPs := constants.DefaultAllowedIPsIPv4
+
+	// Validate required flags; the picker can browse all countries and server names imply theirs
+	if raw.countries == "" && requireCountries && !cfg.Interactive && raw.servers == "" {
+		return fmt.Errorf("countries flag is required")
+	}
+
+	// Validate certificate lifetime and features
+	if err := validateCertificateFlags(fs, cfg); err != nil {
+		return err
+	}
+	switch cfg.OnCollision {
+	case CollisionRefuse, CollisionSuffix, CollisionRevoke:
+	default:
+		return fmt.Errorf("invalid on-collision: %s (expected refuse, suffix or revoke)", cfg.OnCollision)
+	}
+	if cfg.PortForwarding && cfg.FreeOnly {
+		return fmt.Errorf("port forwarding is not available on Free tier servers")
+	}
+
+	if err := finalizeServerFilters(cfg, raw); err != nil {
+		return err
 	}
 
 	// Set defaults based on IPv6 setting
@@ -89,10 +236,31 @@ func Parse() (*Config, error) {
 	cfg.DNSServers = parseCommaSeparatedList(dnsServersFlag)
 	cfg.AllowedIPs = parseCommaSeparatedList(allowedIPsFlag)
 
-	// Clean up username
-	cfg.Username = validation.CleanUsername(cfg.Username)
+	return nil
+}
 
-	return cfg, nil
+// finalizeServerFilters parses the server selection lists and validates the country codes
+func finalizeServerFilters(cfg *Config, raw *generateFlags) error {
+	cfg.Countries = parseCountries(raw.countries)
+	for _, country := range cfg.Countries {
+		if !validation.IsValidCountryCode(country) {
+			return fmt.Errorf("invalid country code: %s", country)
+		}
+	}
+	cfg.Cities = parseCommaSeparatedList(raw.cities)
+	cfg.Servers = parseCommaSeparatedList(raw.servers)
+	return nil
+}
+
+// isFlagSet reports whether a flag was given on the command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 // parseCommaSeparatedList parses a comma-separated string into a trimmed slice
@@ -113,8 +281,21 @@ func parseCountries(countriesFlag string) []string {
 	return parseCommaSeparatedList(strings.ToUpper(countriesFlag))
 }
 
-// PrintUsage prints usage information
+// PrintUsage prints usage information for config generation
 func PrintUsage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s -username <username> -countries <country-codes> [options]\n\n", os.Args[0])
-	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Usage: %s [generate] -username <username> -countries <country-codes> [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Generates a WireGuard configuration for the best matching server. Run '%s help' for other commands.\n\n", os.Args[0])
+	printDefaults(newGenerateFlagSet(&Config{}, &generateFlags{}))
+}
+
+// printDefaults prints the defaults of a flag set to stderr, including the config file flags
+// that parseFlags adds and the environment variable of each flag
+func printDefaults(fs *flag.FlagSet) {
+	registerConfigFileFlags(fs, &Config{})
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Usage += " [$" + EnvName(f.Name) + "]"
+	})
+	fs.SetOutput(os.Stderr)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
 }
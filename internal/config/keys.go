@@ -0,0 +1,77 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// Key store actions
+const (
+	KeysActionList   = "list"
+	KeysActionExport = "export"
+	KeysActionImport = "import"
+)
+
+// Key export formats
+const (
+	KeyFormatJSON = "json"
+	KeyFormatKey  = "key"
+)
+
+// newKeysFlagSet creates the flag set for the keys subcommand
+func newKeysFlagSet(cfg *Config) *flag.FlagSet {
+	fs := newFlagSet("keys")
+
+	fs.StringVar(&cfg.KeyStoreDir, "key-store", "", "Key store directory (default ~/"+constants.KeyStoreDirName+")")
+	fs.StringVar(&cfg.KeyFormat, "format", KeyFormatJSON, "Export format: json (key and certificate metadata) or key (bare WireGuard private key)")
+	fs.StringVar(&cfg.DeviceName, "device-name", "", "Device name to import the key as (taken from the file for JSON exports)")
+
+	return fs
+}
+
+// ParseKeys parses the arguments of the keys subcommand: keys <list|export|import> [options] [device|file]
+func ParseKeys(args []string) (*Config, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("keys requires an action: list, export or import")
+	}
+
+	cfg := &Config{KeysAction: args[0]}
+	fs := newKeysFlagSet(cfg)
+	if _, err := parseFlags(fs, cfg, args[1:]); err != nil {
+		return nil, err
+	}
+	cfg.KeysTargets = fs.Args()
+
+	switch cfg.KeysAction {
+	case KeysActionList:
+		if len(cfg.KeysTargets) > 0 {
+			return nil, fmt.Errorf("keys list does not accept arguments")
+		}
+	case KeysActionExport:
+		if len(cfg.KeysTargets) != 1 {
+			return nil, fmt.Errorf("keys export requires exactly one device name")
+		}
+		if cfg.KeyFormat != KeyFormatJSON && cfg.KeyFormat != KeyFormatKey {
+			return nil, fmt.Errorf("invalid -format value: %s (expected json or key)", cfg.KeyFormat)
+		}
+	case KeysActionImport:
+		if len(cfg.KeysTargets) != 1 {
+			return nil, fmt.Errorf("keys import requires exactly one file (use - for stdin)")
+		}
+	default:
+		return nil, fmt.Errorf("unknown keys action: %s (expected list, export or import)", cfg.KeysAction)
+	}
+
+	return cfg, nil
+}
+
+// PrintKeysUsage prints usage information for the keys subcommand
+func PrintKeysUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s keys list [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s keys export [options] <device>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s keys import [options] <file|->\n\n", os.Args[0])
+	printDefaults(newKeysFlagSet(&Config{}))
+}
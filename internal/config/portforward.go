@@ -0,0 +1,64 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// Port forwarding protocols
+const (
+	PortForwardBoth = "both"
+	PortForwardTCP  = "tcp"
+	PortForwardUDP  = "udp"
+)
+
+// newPortForwardFlagSet creates the flag set for the port-forward subcommand
+func newPortForwardFlagSet(cfg *Config) *flag.FlagSet {
+	fs := newFlagSet("port-forward")
+
+	fs.StringVar(&cfg.Gateway, "gateway", constants.DefaultNATPMPGateway, "NAT-PMP gateway (the VPN tunnel gateway)")
+	fs.StringVar(&cfg.PortForwardProtocol, "protocol", PortForwardBoth, "Protocols to map: both, tcp or udp")
+	fs.DurationVar(&cfg.PortMappingLifetime, "lifetime", constants.DefaultPortMappingLifetime, "Requested mapping lifetime; mappings are renewed at half of it")
+	fs.StringVar(&cfg.PortFile, "port-file", "", "Write the forwarded port to this file whenever it changes")
+	fs.StringVar(&cfg.PortHook, "hook", "", "Shell command to run whenever the forwarded port changes (port in $PORT and $1)")
+	fs.BoolVar(&cfg.Once, "once", false, "Map the port once and exit instead of renewing the mapping")
+
+	registerLogFlags(fs, cfg)
+
+	return fs
+}
+
+// ParsePortForward parses the arguments of the port-forward subcommand
+func ParsePortForward(args []string) (*Config, error) {
+	cfg := &Config{}
+	fs := newPortForwardFlagSet(cfg)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	switch cfg.PortForwardProtocol {
+	case PortForwardBoth, PortForwardTCP, PortForwardUDP:
+	default:
+		return nil, fmt.Errorf("invalid -protocol value: %s (expected both, tcp or udp)", cfg.PortForwardProtocol)
+	}
+	if cfg.PortMappingLifetime < 2*time.Second {
+		return nil, fmt.Errorf("lifetime must be at least 2s")
+	}
+
+	return cfg, nil
+}
+
+// PrintPortForwardUsage prints usage information for the port-forward subcommand
+func PrintPortForwardUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s port-forward [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Requests a forwarded port from the VPN gateway (NAT-PMP) and keeps renewing it.\n")
+	fmt.Fprintf(os.Stderr, "The tunnel must be up and its certificate must have port forwarding enabled.\n\n")
+	printDefaults(newPortForwardFlagSet(&Config{}))
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newRenewFlagSet creates the flag set for the renew subcommand. It accepts all generate
+// flags, which only take effect where the renewal needs them (e.g. server selection with -reselect).
+func newRenewFlagSet(cfg *Config, raw *generateFlags) *flag.FlagSet {
+	fs := newGenerateFlagSet(cfg, raw)
+
+	fs.StringVar(&cfg.KeyFile, "key-file", "", "Read the private key from this file (bare key or WireGuard config) instead of -output")
+	fs.BoolVar(&cfg.Reselect, "reselect", false, "Select a new server instead of keeping the existing endpoint (requires -countries, -servers or -interactive)")
+
+	return fs
+}
+
+// ParseRenew parses the arguments of the renew subcommand
+func ParseRenew(args []string) (*Config, error) {
+	cfg := &Config{}
+	raw := &generateFlags{}
+
+	fs := newRenewFlagSet(cfg, raw)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if cfg.PublicKey != "" {
+		return nil, fmt.Errorf("renew uses the key of the existing config; -public-key is not supported")
+	}
+
+	// Templates are expanded with the selected server
+	if strings.Contains(cfg.DeviceName, "{{") && !cfg.Reselect {
+		return nil, fmt.Errorf("device name templates require -reselect")
+	}
+
+	if cfg.DryRun {
+		return nil, fmt.Errorf("-dry-run is not supported by renew")
+	}
+	if cfg.Interactive && !cfg.Reselect {
+		return nil, fmt.Errorf("-interactive requires -reselect")
+	}
+
+	// Countries are only needed when a new server is selected
+	if err := finalizeGenerate(fs, cfg, raw, cfg.Reselect); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// PrintRenewUsage prints usage information for the renew subcommand
+func PrintRenewUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s renew -username <username> [-output <config>] [-key-file <file>] [-reselect -countries <country-codes>] [options]\n\n", os.Args[0])
+	printDefaults(newRenewFlagSet(&Config{}, &generateFlags{}))
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// newServersFlagSet creates the flag set for the servers subcommand
+func newServersFlagSet(cfg *Config, raw *generateFlags) *flag.FlagSet {
+	fs := newFlagSet("servers")
+	registerAuthFlags(fs, cfg)
+	registerServerFlags(fs, cfg, raw)
+	return fs
+}
+
+// ParseServers parses the arguments of the servers subcommand. Without -countries, servers of
+// all countries are listed.
+func ParseServers(args []string) (*Config, error) {
+	cfg := &Config{}
+	raw := &generateFlags{}
+
+	fs := newServersFlagSet(cfg, raw)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if err := finalizeAuth(cfg); err != nil {
+		return nil, err
+	}
+	if err := finalizeServerFilters(cfg, raw); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PrintServersUsage prints usage information for the servers subcommand
+func PrintServersUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s servers [-countries <country-codes>] [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Lists the servers generate selects from, best first.\n\n")
+	printDefaults(newServersFlagSet(&Config{}, &generateFlags{}))
+}
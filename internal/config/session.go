@@ -0,0 +1,92 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// newLoginFlagSet creates the flag set for the login subcommand
+func newLoginFlagSet(cfg *Config) *flag.FlagSet {
+	fs := newFlagSet("login")
+	registerAuthFlags(fs, cfg)
+	return fs
+}
+
+// ParseLogin parses the arguments of the login subcommand
+func ParseLogin(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	fs := newLoginFlagSet(cfg)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if cfg.NoSession {
+		return nil, fmt.Errorf("login saves the session; -no-session cannot be used")
+	}
+
+	if err := finalizeAuth(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PrintLoginUsage prints usage information for the login subcommand
+func PrintLoginUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s login [-username <username>] [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Authenticates and saves the session for later commands.\n\n")
+	printDefaults(newLoginFlagSet(&Config{}))
+}
+
+// newLogoutFlagSet creates the flag set for the logout subcommand
+func newLogoutFlagSet(cfg *Config) *flag.FlagSet {
+	fs := newFlagSet("logout")
+	registerAPIFlags(fs, cfg)
+	return fs
+}
+
+// ParseLogout parses the arguments of the logout subcommand
+func ParseLogout(args []string) (*Config, error) {
+	cfg := &Config{}
+
+	fs := newLogoutFlagSet(cfg)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if err := finalizeAuth(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PrintLogoutUsage prints usage information for the logout subcommand
+func PrintLogoutUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s logout [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Revokes the saved session and deletes it.\n\n")
+	printDefaults(newLogoutFlagSet(&Config{}))
+}
+
+// ParseSession parses the arguments of the session subcommand, which takes no options
+func ParseSession(args []string) (*Config, error) {
+	fs := newFlagSet("session")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return &Config{}, nil
+}
+
+// PrintSessionUsage prints usage information for the session subcommand
+func PrintSessionUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s session\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Shows the saved session without contacting the API.\n")
+}
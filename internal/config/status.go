@@ -0,0 +1,48 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/pkg/timeutil"
+)
+
+// newStatusFlagSet creates the flag set for the status subcommand
+func newStatusFlagSet(cfg *Config, warn *string) *flag.FlagSet {
+	fs := newFlagSet("status")
+
+	fs.StringVar(&cfg.KeyStoreDir, "key-store", "", "Key store directory (default ~/"+constants.KeyStoreDirName+")")
+	fs.StringVar(warn, "warn", constants.DefaultExpiryWarn, "Exit with an error if a certificate expires within this duration (e.g., 12h, 7d)")
+
+	return fs
+}
+
+// ParseStatus parses the arguments of the status subcommand: status [options] [config-file...]
+func ParseStatus(args []string) (*Config, error) {
+	cfg := &Config{}
+	var warn string
+
+	fs := newStatusFlagSet(cfg, &warn)
+	if _, err := parseFlags(fs, cfg, args); err != nil {
+		return nil, err
+	}
+	cfg.StatusTargets = fs.Args()
+
+	duration, err := timeutil.ParseDuration(warn)
+	if err != nil || duration < 0 {
+		return nil, fmt.Errorf("invalid -warn value: %s", warn)
+	}
+	cfg.ExpiryWarning = duration
+
+	return cfg, nil
+}
+
+// PrintStatusUsage prints usage information for the status subcommand
+func PrintStatusUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s status [options] [config-file...]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Reports certificate expiry of the given config files, or of all keys in the key store.\n\n")
+	var warn string
+	printDefaults(newStatusFlagSet(&Config{}, &warn))
+}
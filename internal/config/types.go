@@ -1,23 +1,35 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Config holds all configuration options
 type Config struct {
 	// Authentication
-	Username string
-	Password string
+	Username        string
+	Password        string
+	MailboxPassword string
+	CaptchaToken    string
 
 	// Server selection
 	Countries      []string
+	Cities         []string
+	Servers        []string
 	P2PServersOnly bool
 	SecureCoreOnly bool
 	FreeOnly       bool
+	Interactive    bool
 
 	// Output configuration
 	OutputFile       string
 	ClientPrivateKey string
 	DeviceName       string
+	OnCollision      string
+	PublicKey        string
+	PeerOnly         bool
+	DryRun           bool
 
 	// Network configuration
 	DNSServers        []string
@@ -26,7 +38,60 @@ type Config struct {
 	EnableIPv6        bool
 
 	// Certificate configuration
-	Duration string
+	Duration       string
+	ValidUntil     string
+	NetShieldLevel int
+	ModerateNAT    bool
+	PortForwarding bool
+
+	// Renewal
+	KeyFile  string
+	Reselect bool
+
+	// Key store
+	KeyStoreDir string
+	NoKeyStore  bool
+	KeysAction  string
+	KeysTargets []string
+	KeyFormat   string
+
+	// Status
+	StatusTargets []string
+	ExpiryWarning time.Duration
+
+	// Port forwarding
+	Gateway             string
+	PortForwardProtocol string
+	PortMappingLifetime time.Duration
+	PortFile            string
+	PortHook            string
+	Once                bool
+
+	// Batch generation
+	BatchManifest       string
+	Concurrency         int
+	ReportFile          string
+	Resume              bool
+	ReservedDeviceNames map[string]bool // Names of the other devices of a batch, which a suffixed name must not take
+
+	// Certificate management
+	CertsAction  string
+	CertsTargets []string
+	OlderThan    string
+	AssumeYes    bool
+	Features     FeatureUpdate
+
+	// Diagnostics
+	DoctorFormat string
+
+	// Shell completion
+	CompletionShell  string
+	CompletionValues string
+	CompletionWord   string
+
+	// Config file
+	ConfigFile string
+	Profile    string
 
 	// Session management
 	ClearSession    bool
@@ -35,8 +100,14 @@ type Config struct {
 	SessionDuration string
 
 	// Advanced configuration
-	APIURL string
-	Debug  bool
+	APIURL  string
+	Retries int
+	Timeout time.Duration
+
+	// Logging
+	Debug     bool
+	LogLevel  string
+	LogFormat string
 }
 
 // ValidateCredentials checks if we have the required credentials
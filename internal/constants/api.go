@@ -3,12 +3,24 @@ package constants
 
 // API endpoints
 const (
-	DefaultAPIURL   = "https://vpn-api.proton.me"
-	AuthInfoPath    = "/core/v4/auth/info"
-	AuthPath        = "/core/v4/auth"
-	RefreshPath     = "/auth/refresh"
-	CertificatePath = "/vpn/v1/certificate"
-	LogicalsPath    = "/vpn/v1/logicals"
+	DefaultAPIURL    = "https://vpn-api.proton.me"
+	AuthInfoPath     = "/core/v4/auth/info"
+	AuthPath         = "/core/v4/auth"
+	TwoFactorPath    = "/core/v4/auth/2fa"
+	RefreshPath      = "/auth/refresh"
+	ScopesPath       = "/core/v4/auth/scopes"
+	KeySaltsPath     = "/core/v4/keys/salts"
+	UsersPath        = "/core/v4/users"
+	CertificatePath  = "/vpn/v1/certificate"
+	CertificatesPath = "/vpn/v1/certificate/all"
+	LogicalsPath     = "/vpn/v1/logicals"
+	PingPath         = "/tests/ping"
+)
+
+// Human verification
+const (
+	HumanVerificationURL     = "https://verify.proton.me"
+	HumanVerificationCaptcha = "captcha"
 )
 
 // API version headers
@@ -19,7 +31,9 @@ const (
 
 // API response codes
 const (
-	APICodeSuccess = 1000
+	APICodeSuccess          = 1000
+	APICodeAppVersionBad    = 5003 // The app version is no longer supported
+	APICodeAppVersionLegacy = 5005 // The app version is too old to be supported
 )
 
 // Server/feature status values
@@ -1,14 +1,37 @@
 package constants
 
+import "time"
+
 // Certificate defaults
 const (
 	DefaultCertDuration = "365d"
 	MaxCertDuration     = 365 // days
 	CertMode            = "persistent"
 	PublicKeyMode       = "EC"
+	DefaultExpiryWarn   = "7d" // status fails when a certificate expires within this
+)
+
+// NetShield levels
+const (
+	NetShieldOff         = 0
+	NetShieldMalware     = 1 // Block malware
+	NetShieldAdsTrackers = 2 // Block malware, ads and trackers
 )
 
 // Server selection defaults
 const (
 	DefaultP2POnly = true
 )
+
+// Batch defaults
+const (
+	DefaultBatchConcurrency = 4
+)
+
+// API client defaults
+const (
+	DefaultAPITimeout = 60 * time.Second // Per API call, including retries
+	DefaultAPIRetries = 3
+	RetryBaseDelay    = 500 * time.Millisecond
+	RetryMaxDelay     = 30 * time.Second
+)
@@ -7,3 +7,22 @@ const (
 	SessionRefreshDays   = 7       // Refresh when less than 7 days remain
 	SessionExpirySeconds = 2592000 // 30 days in seconds (from API)
 )
+
+// Config file defaults
+const (
+	ConfigDirName  = "protonvpn-wg" // Under $XDG_CONFIG_HOME
+	ConfigFileName = "config.yaml"
+)
+
+// Server cache defaults
+const (
+	CacheDirName        = "protonvpn-wg" // Under $XDG_CACHE_HOME
+	ServerCacheFileName = "servers.json"
+)
+
+// Key store defaults
+const (
+	KeyStoreDirName = ".protonvpn-wg-keys"
+	KeyStoreDirMode = 0o700 // Owner only
+	KeyFileMode     = 0o600 // Read/write for owner only
+)
@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 // WireGuard defaults
 const (
 	WireGuardPort = 51820
@@ -15,3 +17,11 @@ const (
 	DefaultDNSIPv6        = "2a07:b944::2:1"
 	DefaultAllowedIPsIPv6 = "::/0"
 )
+
+// Port forwarding (NAT-PMP) defaults
+const (
+	DefaultNATPMPGateway       = "10.2.0.1"       // Tunnel gateway
+	NATPMPInternalPort         = 1                // ProtonVPN gateways forward to the same port on the client, whatever is requested
+	DefaultPortMappingLifetime = 60 * time.Second // Mappings are renewed at half their lifetime
+	PortMappingRetryDelay      = 5 * time.Second
+)
@@ -0,0 +1,274 @@
+// Package doctor diagnoses common setup problems: the saved session, API access and the clock.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/pkg/timeutil"
+)
+
+// Status is the outcome of a check
+type Status string
+
+// Check statuses, from best to worst. Skipped checks depend on one that failed.
+const (
+	Pass Status = "pass"
+	Skip Status = "skip"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Clock skew thresholds. 2FA codes are valid for 30 seconds.
+const (
+	clockSkewWarn = 30 * time.Second
+	clockSkewFail = 5 * time.Minute
+)
+
+// Check is the result of one diagnostic check
+type Check struct {
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Report holds the results of all checks. Its status is the worst status of its checks.
+type Report struct {
+	Status Status  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// add appends a check to the report
+func (r *Report) add(check Check) {
+	r.Checks = append(r.Checks, check)
+	if severity(check.Status) > severity(r.Status) {
+		r.Status = check.Status
+	}
+}
+
+// severity orders statuses; a skipped check doesn't make the report worse
+func severity(status Status) int {
+	switch status {
+	case Warn:
+		return 1
+	case Fail:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Run runs all checks against the API configured in cfg. It never prompts: the saved session is
+// checked as it is.
+func Run(ctx context.Context, cfg *config.Config) *Report {
+	apiClient := api.NewClient(cfg.APIURL, api.WithRetries(cfg.Retries), api.WithTimeout(cfg.Timeout))
+	return run(ctx, cfg.APIURL, apiClient, auth.NewSessionStore())
+}
+
+// run runs the checks with the given API client and session store
+func run(ctx context.Context, apiURL string, apiClient *api.Client, store *auth.SessionStore) *Report {
+	report := &Report{Status: Pass}
+	savedSession := checkSessionFile(report, store)
+	reachable := checkAPI(ctx, report, apiURL, apiClient)
+	checkSession(ctx, report, apiClient, store, savedSession, reachable)
+	return report
+}
+
+// checkSessionFile checks that the session file is private and readable, and returns the saved
+// session, if any
+func checkSessionFile(report *Report, store *auth.SessionStore) *auth.SavedSession {
+	check := Check{Name: "session_file", Title: "Session file"}
+	path := store.GetPath()
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		check.Status = Warn
+		check.Message = fmt.Sprintf("No saved session at %s", path)
+		check.Hint = "Run 'login' to save a session, so later commands don't prompt for the password"
+		report.add(check)
+		return nil
+	}
+	if err != nil {
+		check.Status = Fail
+		check.Message = err.Error()
+		check.Hint = "Check the permissions of the home directory"
+		report.add(check)
+		return nil
+	}
+
+	if mode := info.Mode().Perm(); mode&0o077 != 0 {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s is accessible by other users (mode %04o)", path, mode)
+		check.Hint = fmt.Sprintf("Run 'chmod 600 %s', and 'logout' and 'login' if others may have read the tokens", path)
+		report.add(check)
+		return nil
+	}
+
+	savedSession, err := store.Info()
+	if err != nil || savedSession == nil || savedSession.Session == nil {
+		check.Status = Fail
+		check.Message = fmt.Sprintf("%s can't be read: %v", path, err)
+		check.Hint = fmt.Sprintf("Delete %s and run 'login' again", path)
+		report.add(check)
+		return nil
+	}
+
+	check.Status = Pass
+	check.Message = fmt.Sprintf("%s (mode %04o, user %s)", path, info.Mode().Perm(), savedSession.Username)
+	report.add(check)
+	return savedSession
+}
+
+// checkAPI checks that the API is reachable and accepts the app version, and compares the
+// clocks. It reports whether the API is reachable.
+func checkAPI(ctx context.Context, report *Report, apiURL string, apiClient *api.Client) bool {
+	start := time.Now()
+	serverTime, err := apiClient.Ping(ctx)
+	latency := time.Since(start)
+
+	apiErr, isAPIErr := api.AsError(err)
+	if err != nil && !isAPIErr {
+		report.add(Check{
+			Name: "api", Title: "API reachability", Status: Fail,
+			Message: fmt.Sprintf("%s is unreachable: %v", apiURL, err),
+			Hint:    "Check the network connection, DNS, proxy settings (HTTPS_PROXY) and -api-url",
+		})
+		report.add(Check{Name: "app_version", Title: "App version", Status: Skip, Message: "The API is unreachable"})
+		report.add(Check{Name: "clock", Title: "Clock", Status: Skip, Message: "The API is unreachable"})
+		return false
+	}
+	report.add(Check{
+		Name: "api", Title: "API reachability", Status: Pass,
+		Message: fmt.Sprintf("%s responded in %s", apiURL, latency.Round(time.Millisecond)),
+	})
+
+	if isAPIErr && (apiErr.Code == constants.APICodeAppVersionBad || apiErr.Code == constants.APICodeAppVersionLegacy) {
+		report.add(Check{
+			Name: "app_version", Title: "App version", Status: Fail,
+			Message: fmt.Sprintf("The API rejects %s: %s", constants.AppVersion, apiErr.Message),
+			Hint:    "Update to the latest release, which sends a supported app version",
+		})
+	} else {
+		report.add(Check{
+			Name: "app_version", Title: "App version", Status: Pass,
+			Message: fmt.Sprintf("%s is accepted", constants.AppVersion),
+		})
+	}
+
+	report.add(checkClock(serverTime, start.Add(latency/2)))
+	return true
+}
+
+// checkClock compares the server time to the local time the response was received at
+func checkClock(serverTime, localTime time.Time) Check {
+	check := Check{Name: "clock", Title: "Clock"}
+	if serverTime.IsZero() {
+		check.Status = Skip
+		check.Message = "The API response has no Date header"
+		return check
+	}
+
+	// The Date header has a resolution of one second
+	skew := localTime.Sub(serverTime).Round(time.Second)
+	direction := "ahead of"
+	if skew < 0 {
+		skew = -skew
+		direction = "behind"
+	}
+
+	switch {
+	case skew >= clockSkewFail:
+		check.Status = Fail
+	case skew >= clockSkewWarn:
+		check.Status = Warn
+	default:
+		check.Status = Pass
+		check.Message = fmt.Sprintf("In sync with the API (within %s)", clockSkewWarn)
+		return check
+	}
+	check.Message = fmt.Sprintf("The local clock is %s %s the API; 2FA codes and certificate times will be off", skew, direction)
+	check.Hint = "Enable time synchronization (e.g. 'timedatectl set-ntp true')"
+	return check
+}
+
+// checkSession checks that the API accepts the saved session and that it has the VPN scope.
+// A refreshed session is saved, since refreshing rotates the tokens.
+func checkSession(ctx context.Context, report *Report, apiClient *api.Client, store *auth.SessionStore, savedSession *auth.SavedSession, reachable bool) {
+	const name, title = "session", "Session"
+	const scopeName, scopeTitle = "vpn_scope", "VPN scope"
+
+	switch {
+	case savedSession == nil:
+		report.add(Check{Name: name, Title: title, Status: Skip, Message: "No usable saved session"})
+		report.add(Check{Name: scopeName, Title: scopeTitle, Status: Skip, Message: "No usable saved session"})
+		return
+	case !time.Now().Before(savedSession.ExpiresAt):
+		report.add(Check{
+			Name: name, Title: title, Status: Fail,
+			Message: fmt.Sprintf("The session of %s expired on %s", savedSession.Username, savedSession.ExpiresAt.Local().Format("2006-01-02 15:04 MST")),
+			Hint:    "Run 'login' to authenticate again",
+		})
+		report.add(Check{Name: scopeName, Title: scopeTitle, Status: Skip, Message: "The session expired"})
+		return
+	case !reachable:
+		report.add(Check{Name: name, Title: title, Status: Skip, Message: "The API is unreachable"})
+		report.add(Check{Name: scopeName, Title: scopeTitle, Status: Skip, Message: "The API is unreachable"})
+		return
+	}
+
+	apiClient.SetSession(savedSession.Session)
+	apiClient.OnRefresh(func(session *api.Session) {
+		_ = store.Save(session, savedSession.Username, time.Until(savedSession.ExpiresAt))
+	})
+	if !auth.VerifySession(ctx, apiClient) {
+		report.add(Check{
+			Name: name, Title: title, Status: Fail,
+			Message: fmt.Sprintf("The API rejects the session of %s", savedSession.Username),
+			Hint:    "Run 'login' to authenticate again",
+		})
+		report.add(Check{Name: scopeName, Title: scopeTitle, Status: Skip, Message: "The session is rejected"})
+		return
+	}
+
+	remaining := time.Until(savedSession.ExpiresAt)
+	if remaining < time.Duration(constants.SessionRefreshDays)*24*time.Hour {
+		report.add(Check{
+			Name: name, Title: title, Status: Warn,
+			Message: fmt.Sprintf("The session of %s is valid but expires in %s", savedSession.Username, timeutil.HumanizeDuration(remaining)),
+			Hint:    "The next command refreshes it, or run 'login -force-refresh'",
+		})
+	} else {
+		report.add(Check{
+			Name: name, Title: title, Status: Pass,
+			Message: fmt.Sprintf("The session of %s is valid for %s", savedSession.Username, timeutil.HumanizeDuration(remaining)),
+		})
+	}
+
+	// Verification may have refreshed the session
+	hasVPN, hasTwoFactor := auth.CheckSessionScopes(apiClient.Session())
+	switch {
+	case hasVPN:
+		report.add(Check{Name: scopeName, Title: scopeTitle, Status: Pass, Message: "The session has the VPN scope"})
+	case hasTwoFactor:
+		report.add(Check{
+			Name: scopeName, Title: scopeTitle, Status: Warn,
+			Message: "The session gets the VPN scope once 2FA is completed",
+			Hint:    "Run 'login' and enter the 2FA code",
+		})
+	default:
+		report.add(Check{
+			Name: scopeName, Title: scopeTitle, Status: Fail,
+			Message: "The session lacks the VPN scope; the account may have no VPN access",
+			Hint:    "Check the account's VPN plan at https://account.proton.me, then run 'login -clear-session'",
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/auth"
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// statuses returns the status of each check by name
+func statuses(report *Report) map[string]Status {
+	result := make(map[string]Status)
+	for _, check := range report.Checks {
+		result[check.Name] = check.Status
+	}
+	return result
+}
+
+func TestRunHealthy(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == constants.LogicalsPath && r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Code": constants.APICodeSuccess})
+	}))
+	defer server.Close()
+
+	store := auth.NewSessionStore()
+	session := &api.Session{UID: "uid", AccessToken: "access", RefreshToken: "refresh", Scopes: []string{"vpn"}, ExpiresIn: constants.SessionExpirySeconds}
+	if err := store.Save(session, "alice", 0); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	report := run(context.Background(), server.URL, api.NewClient(server.URL), store)
+	want := map[string]Status{"session_file": Pass, "api": Pass, "app_version": Pass, "clock": Pass, "session": Pass, "vpn_scope": Pass}
+	for name, status := range statuses(report) {
+		if want[name] != status {
+			t.Errorf("%s: got %s, want %s", name, status, want[name])
+		}
+	}
+	if report.Status != Pass {
+		t.Errorf("Report status %s, want pass", report.Status)
+	}
+}
+
+func TestRunProblems(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-10*time.Minute).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Code": constants.APICodeAppVersionBad, "Error": "This version of the app is no longer supported"})
+	}))
+	defer server.Close()
+
+	store := auth.NewSessionStore()
+	session := &api.Session{UID: "uid", AccessToken: "access", RefreshToken: "refresh", ExpiresIn: constants.SessionExpirySeconds}
+	if err := store.Save(session, "alice", 0); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.Chmod(store.GetPath(), 0o644); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+
+	report := run(context.Background(), server.URL, api.NewClient(server.URL, api.WithRetries(0)), store)
+	want := map[string]Status{"session_file": Fail, "api": Pass, "app_version": Fail, "clock": Fail, "session": Skip, "vpn_scope": Skip}
+	for name, status := range statuses(report) {
+		if want[name] != status {
+			t.Errorf("%s: got %s, want %s", name, status, want[name])
+		}
+	}
+	if report.Status != Fail {
+		t.Errorf("Report status %s, want fail", report.Status)
+	}
+}
+
+func TestCheckClock(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		offset time.Duration
+		want   Status
+	}{
+		{2 * time.Second, Pass},
+		{-45 * time.Second, Warn},
+		{6 * time.Minute, Fail},
+	}
+	for _, tt := range tests {
+		if got := checkClock(now.Add(tt.offset), now).Status; got != tt.want {
+			t.Errorf("Offset %s: got %s, want %s", tt.offset, got, tt.want)
+		}
+	}
+	if got := checkClock(time.Time{}, now).Status; got != Skip {
+		t.Errorf("Missing date: got %s, want skip", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Package errclass classifies errors, so scripts can tell failures apart by exit code.
+package errclass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// Class is the kind of failure an error represents
+type Class string
+
+// Error classes
+const (
+	Unknown      Class = "error"
+	InvalidInput Class = "invalid_input"
+	AuthFailed   Class = "auth_failed"
+	TwoFactor    Class = "2fa_required"
+	Captcha      Class = "captcha_required"
+	NoServers    Class = "no_matching_servers"
+	API          Class = "api_error"
+	Network      Class = "network_error"
+	IO           Class = "io_error"
+	Interrupted  Class = "interrupted"
+)
+
+// exitCodes maps the classes to the process exit codes
+var exitCodes = map[Class]int{
+	Unknown:      1,
+	InvalidInput: 2,
+	AuthFailed:   3,
+	TwoFactor:    4,
+	Captcha:      5,
+	NoServers:    6,
+	API:          7,
+	Network:      8,
+	IO:           9,
+	Interrupted:  130,
+}
+
+// Classes returns all classes in exit code order
+func Classes() []Class {
+	return []Class{Unknown, InvalidInput, AuthFailed, TwoFactor, Captcha, NoServers, API, Network, IO, Interrupted}
+}
+
+// ExitCode returns the exit code of the class
+func (c Class) ExitCode() int {
+	if code, ok := exitCodes[c]; ok {
+		return code
+	}
+	return exitCodes[Unknown]
+}
+
+// Classifier is implemented by errors that know their class
+type Classifier interface {
+	ErrorClass() Class
+}
+
+// Error attaches a class to an error
+type Error struct {
+	Class Class
+	Err   error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the classified error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass implements Classifier
+func (e *Error) ErrorClass() Class {
+	return e.Class
+}
+
+// New creates an error of the class with a formatted message
+func New(class Class, format string, args ...any) error {
+	return &Error{Class: class, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap attaches a class to err; a nil err stays nil
+func Wrap(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// Of returns the class of err. Cancellation wins over everything else, then the outermost
+// classified error in the chain; file system errors are I/O errors.
+func Of(err error) Class {
+	if errors.Is(err, context.Canceled) {
+		return Interrupted
+	}
+
+	var classifier Classifier
+	if errors.As(err, &classifier) {
+		return classifier.ErrorClass()
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return IO
+	}
+	return Unknown
+}
@@ -0,0 +1,46 @@
+package errclass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"plain", errors.New("boom"), Unknown},
+		{"classified", New(NoServers, "no servers"), NoServers},
+		{"wrapped", fmt.Errorf("failed: %w", Wrap(Network, errors.New("dial"))), Network},
+		{"outermost wins", Wrap(AuthFailed, Wrap(API, errors.New("401"))), AuthFailed},
+		{"path error", fmt.Errorf("read: %w", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}), IO},
+		{"cancelled", Wrap(Network, context.Canceled), Interrupted},
+	}
+	for _, tt := range tests {
+		if got := Of(tt.err); got != tt.want {
+			t.Errorf("%s: Of() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	seen := make(map[int]Class)
+	for _, class := range Classes() {
+		code := class.ExitCode()
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s share exit code %d", class, other, code)
+		}
+		seen[code] = class
+	}
+	if Class("unknown").ExitCode() != Unknown.ExitCode() {
+		t.Error("Expected unlisted classes to exit like Unknown")
+	}
+	if Wrap(IO, nil) != nil {
+		t.Error("Expected wrapping nil to return nil")
+	}
+}
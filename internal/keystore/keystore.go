@@ -0,0 +1,179 @@
+// Package keystore persists client key pairs and their certificate metadata, so devices can be
+// renewed, revoked or re-rendered by name without parsing WireGuard configuration files.
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/pkg/fileutil"
+)
+
+// fileExtension is the extension of key store entries
+const fileExtension = ".json"
+
+// ErrNotFound is returned when no key is stored for a device
+var ErrNotFound = errors.New("no stored key for device")
+
+// Entry is a device's key pair and the metadata of its latest certificate
+type Entry struct {
+	DeviceName     string                  `json:"device_name"`
+	PrivateKey     string                  `json:"private_key"`
+	SerialNumber   string                  `json:"serial_number,omitempty"`
+	Fingerprint    string                  `json:"fingerprint,omitempty"`
+	ExpirationTime int64                   `json:"expiration_time,omitempty"`
+	RefreshTime    int64                   `json:"refresh_time,omitempty"`
+	Features       api.CertificateFeatures `json:"features"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+}
+
+// NewEntry creates an entry for a private key and the certificate issued for it
+func NewEntry(privateKey string, certificate *api.VPNInfo) *Entry {
+	return &Entry{
+		DeviceName:     certificate.DeviceName,
+		PrivateKey:     privateKey,
+		SerialNumber:   certificate.SerialNumber,
+		Fingerprint:    certificate.ClientKeyFingerprint,
+		ExpirationTime: certificate.ExpirationTime,
+		RefreshTime:    certificate.RefreshTime,
+		Features:       certificate.Features,
+	}
+}
+
+// Store is a directory holding one entry file per device
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns the default key store directory in the user's home directory
+func DefaultDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		// Fallback to current directory
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, constants.KeyStoreDirName)
+}
+
+// Open opens the key store at dir, creating it if needed and tightening its permissions
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, constants.KeyStoreDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create key store: %w", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access key store: %w", err)
+	}
+	if info.Mode().Perm() != constants.KeyStoreDirMode {
+		if err := os.Chmod(dir, constants.KeyStoreDirMode); err != nil {
+			return nil, fmt.Errorf("failed to set key store permissions: %w", err)
+		}
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the key store directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Get returns the entry stored for a device
+func (s *Store) Get(deviceName string) (*Entry, error) {
+	entry, err := s.read(s.path(deviceName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w %q", ErrNotFound, deviceName)
+	}
+	return entry, err
+}
+
+// Put stores an entry, replacing any previous entry for the same device
+func (s *Store) Put(entry *Entry) error {
+	if entry.DeviceName == "" {
+		return fmt.Errorf("cannot store a key without a device name")
+	}
+	entry.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(s.path(entry.DeviceName), data, constants.KeyFileMode); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the entry of a device, if any
+func (s *Store) Delete(deviceName string) error {
+	err := os.Remove(s.path(deviceName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+	return nil
+}
+
+// List returns all entries sorted by device name
+func (s *Store) List() ([]Entry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileExtension) {
+			continue
+		}
+		entry, err := s.read(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].DeviceName < entries[j].DeviceName })
+	return entries, nil
+}
+
+func (s *Store) read(path string) (*Entry, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // paths are built from the key store directory
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", filepath.Base(path), err)
+	}
+	return &entry, nil
+}
+
+// path returns the entry file of a device. Device names are free-form, so anything that isn't
+// safe in a file name (including a leading dot) is percent-encoded.
+func (s *Store) path(deviceName string) string {
+	var name strings.Builder
+	for i := 0; i < len(deviceName); i++ {
+		c := deviceName[i]
+		if isSafeFileNameChar(c) && (i > 0 || c != '.') {
+			name.WriteByte(c)
+		} else {
+			fmt.Fprintf(&name, "%%%02X", c)
+		}
+	}
+	return filepath.Join(s.dir, name.String()+fileExtension)
+}
+
+func isSafeFileNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+}
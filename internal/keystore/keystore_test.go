@@ -0,0 +1,54 @@
+package keystore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"protonvpn-wg-config-generate/internal/api"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("Expected key store directory with mode 0700, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// Names that aren't safe as file names must not escape the directory
+	names := []string{"laptop", "../escape", ".hidden", "a/b c"}
+	for _, name := range names {
+		entry := NewEntry("key-"+name, &api.VPNInfo{DeviceName: name, SerialNumber: "serial-" + name, ExpirationTime: 42})
+		if err := store.Put(entry); err != nil {
+			t.Fatalf("Put(%q) failed: %v", name, err)
+		}
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != len(names) {
+		t.Fatalf("Expected %d files in key store, got %d", len(names), len(files))
+	}
+
+	entry, err := store.Get("../escape")
+	if err != nil || entry.PrivateKey != "key-../escape" || entry.ExpirationTime != 42 {
+		t.Fatalf("Unexpected entry %+v (%v)", entry, err)
+	}
+
+	entries, err := store.List()
+	if err != nil || len(entries) != len(names) || entries[0].DeviceName != "../escape" {
+		t.Fatalf("Unexpected entries %+v (%v)", entries, err)
+	}
+
+	if err := store.Delete("laptop"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get("laptop"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+// Package logging configures the structured logger used for progress, warnings and debug output.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Log levels
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Redacted replaces the values of secret attributes
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are never logged. Keys are compared in lower case
+// with dashes and underscores removed, so "refresh_token" and "RefreshToken" both match.
+var secretKeys = []string{"password", "token", "secret", "authorization", "cookie", "privatekey", "proof", "salt", "totp"}
+
+// ParseLevel parses a log level name
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case LevelDebug:
+		return slog.LevelDebug, nil
+	case LevelInfo:
+		return slog.LevelInfo, nil
+	case LevelWarn, "warning":
+		return slog.LevelWarn, nil
+	case LevelError:
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid log level: %s (expected debug, info, warn or error)", name)
+	}
+}
+
+// New creates a logger writing records of at least the given level to w. The text format leaves
+// out timestamps, which only add noise to interactive runs; the JSON format keeps them.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	minLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: minLevel, ReplaceAttr: redact}
+	switch format {
+	case FormatText:
+		options.ReplaceAttr = func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return redact(groups, attr)
+		}
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format: %s (expected text or json)", format)
+	}
+}
+
+// jsonOutput records whether the default logger writes JSON
+var jsonOutput bool
+
+// Setup installs a logger created by New as the default logger
+func Setup(w io.Writer, level, format string) error {
+	logger, err := New(w, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	jsonOutput = format == FormatJSON
+	return nil
+}
+
+// JSON reports whether the default logger was set up to write JSON, in which case errors are
+// reported as log records too
+func JSON() bool {
+	return jsonOutput
+}
+
+// redact replaces the values of secret attributes and of strings holding a private key
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if IsSecret(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	if attr.Value.Kind() == slog.KindString && strings.Contains(attr.Value.String(), "PRIVATE KEY") {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// IsSecret reports whether values logged under the attribute key are redacted
+func IsSecret(key string) bool {
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	for _, secret := range secretKeys {
+		if strings.Contains(normalized, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package picker
+
+import (
+	"bufio"
+	"unicode"
+)
+
+// keyCode identifies a key press
+type keyCode int
+
+const (
+	keyUnknown keyCode = iota
+	keyRune
+	keyBackspace
+	keyClearSearch
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyNextSort
+	keyPrevSort
+	keyToggleP2P
+	keyToggleSecureCore
+	keyToggleFree
+	keyToggleCountries
+	keyEnter
+	keyEscape
+	keyInterrupt
+)
+
+// key is a decoded key press; r is set for keyRune
+type key struct {
+	code keyCode
+	r    rune
+}
+
+// Control characters
+const (
+	ctrlA     = 0x01
+	ctrlC     = 0x03
+	ctrlF     = 0x06
+	ctrlH     = 0x08
+	tab       = 0x09
+	enter     = 0x0d
+	newline   = 0x0a
+	ctrlN     = 0x0e
+	ctrlP     = 0x10
+	ctrlS     = 0x13
+	ctrlU     = 0x15
+	escape    = 0x1b
+	backspace = 0x7f
+)
+
+// readKey reads one key press from a terminal in raw mode
+func readKey(r *bufio.Reader) (key, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+
+	switch c {
+	case ctrlA:
+		return key{code: keyToggleCountries}, nil
+	case ctrlC:
+		return key{code: keyInterrupt}, nil
+	case ctrlF:
+		return key{code: keyToggleFree}, nil
+	case ctrlH, backspace:
+		return key{code: keyBackspace}, nil
+	case tab:
+		return key{code: keyNextSort}, nil
+	case enter, newline:
+		return key{code: keyEnter}, nil
+	case ctrlN:
+		return key{code: keyDown}, nil
+	case ctrlP:
+		return key{code: keyToggleP2P}, nil
+	case ctrlS:
+		return key{code: keyToggleSecureCore}, nil
+	case ctrlU:
+		return key{code: keyClearSearch}, nil
+	case escape:
+		return readEscape(r)
+	}
+
+	if unicode.IsPrint(c) {
+		return key{code: keyRune, r: c}, nil
+	}
+	return key{code: keyUnknown}, nil
+}
+
+// readEscape decodes an escape sequence. A terminal sends a sequence in one write, so an
+// escape without buffered input is the Esc key itself.
+func readEscape(r *bufio.Reader) (key, error) {
+	if r.Buffered() == 0 {
+		return key{code: keyEscape}, nil
+	}
+
+	introducer, _ := r.ReadByte()
+	if introducer != '[' && introducer != 'O' {
+		return key{code: keyUnknown}, nil
+	}
+
+	// Read up to the final byte of the sequence, e.g. "A" or "5~"
+	var params []byte
+	for r.Buffered() > 0 {
+		c, _ := r.ReadByte()
+		if c >= 0x40 && c <= 0x7e {
+			return decodeEscape(c, params), nil
+		}
+		params = append(params, c)
+	}
+	return key{code: keyUnknown}, nil
+}
+
+// decodeEscape maps the final byte and parameters of an escape sequence to a key
+func decodeEscape(final byte, params []byte) key {
+	switch final {
+	case 'A':
+		return key{code: keyUp}
+	case 'B':
+		return key{code: keyDown}
+	case 'H':
+		return key{code: keyHome}
+	case 'F':
+		return key{code: keyEnd}
+	case 'Z':
+		return key{code: keyPrevSort}
+	case '~':
+		switch string(params) {
+		case "1", "7":
+			return key{code: keyHome}
+		case "4", "8":
+			return key{code: keyEnd}
+		case "5":
+			return key{code: keyPageUp}
+		case "6":
+			return key{code: keyPageDown}
+		}
+	}
+	return key{code: keyUnknown}
+}
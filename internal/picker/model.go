@@ -0,0 +1,369 @@
+// Package picker provides an interactive terminal browser for choosing a server.
+package picker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/vpn"
+)
+
+// SortKey orders the server list
+type SortKey int
+
+// Sort keys, in the order Tab cycles through them
+const (
+	SortScore SortKey = iota
+	SortLoad
+	SortName
+	SortCity
+)
+
+// sortKeyNames are the names shown in the header
+var sortKeyNames = []string{"score", "load", "name", "city"}
+
+// String returns the name of the sort key
+func (k SortKey) String() string {
+	return sortKeyNames[k]
+}
+
+// action is what the picker does after a key press
+type action int
+
+const (
+	actionNone action = iota
+	actionSelect
+	actionCancel
+	actionInterrupt
+)
+
+// Model is the state of the picker, independent of the terminal
+type Model struct {
+	servers []api.LogicalServer
+
+	// filter holds the toggles and is passed to the server selector, so the picker lists
+	// exactly the servers generate would consider
+	filter       config.Config
+	countries    []string
+	allCountries bool
+
+	query   string
+	sortKey SortKey
+	visible []api.LogicalServer
+	cursor  int
+	offset  int
+}
+
+// NewModel creates a picker over the servers, starting from the filters of the configuration
+func NewModel(cfg *config.Config, servers []api.LogicalServer) *Model {
+	m := &Model{
+		servers: servers,
+		filter: config.Config{
+			Countries:      cfg.Countries,
+			P2PServersOnly: cfg.P2PServersOnly,
+			SecureCoreOnly: cfg.SecureCoreOnly,
+			FreeOnly:       cfg.FreeOnly,
+			PortForwarding: cfg.PortForwarding,
+		},
+		countries: cfg.Countries,
+	}
+	m.refresh()
+	return m
+}
+
+// Visible returns the servers matching the filters and the search, in display order
+func (m *Model) Visible() []api.LogicalServer {
+	return m.visible
+}
+
+// Selected returns the highlighted server, or nil if no server matches
+func (m *Model) Selected() *api.LogicalServer {
+	if len(m.visible) == 0 {
+		return nil
+	}
+	return &m.visible[m.cursor]
+}
+
+// SetQuery replaces the search query
+func (m *Model) SetQuery(query string) {
+	m.query = query
+	m.refresh()
+}
+
+// SetSort changes the sort key
+func (m *Model) SetSort(key SortKey) {
+	m.sortKey = key
+	m.refresh()
+}
+
+// Toggle filters
+func (m *Model) toggleP2P() {
+	m.filter.P2PServersOnly = !m.filter.P2PServersOnly
+	m.refresh()
+}
+
+func (m *Model) toggleSecureCore() {
+	m.filter.SecureCoreOnly = !m.filter.SecureCoreOnly
+	m.refresh()
+}
+
+func (m *Model) toggleFree() {
+	m.filter.FreeOnly = !m.filter.FreeOnly
+	m.refresh()
+}
+
+// toggleCountries switches between the countries of the flags and all countries
+func (m *Model) toggleCountries() {
+	if len(m.countries) == 0 {
+		return
+	}
+	m.allCountries = !m.allCountries
+	m.filter.Countries = m.countries
+	if m.allCountries {
+		m.filter.Countries = nil
+	}
+	m.refresh()
+}
+
+// move moves the cursor by delta rows, staying within the list
+func (m *Model) move(delta int) {
+	m.cursor = max(0, min(m.cursor+delta, len(m.visible)-1))
+}
+
+// refresh recomputes the visible servers, keeping the highlighted server if it is still listed
+func (m *Model) refresh() {
+	var current string
+	if server := m.Selected(); server != nil {
+		current = server.ID
+	}
+
+	eligible := vpn.NewServerSelector(&m.filter).Eligible(m.servers)
+	terms := strings.Fields(strings.ToLower(m.query))
+	visible := eligible[:0]
+	for i := range eligible {
+		if matches(&eligible[i], terms) {
+			visible = append(visible, eligible[i])
+		}
+	}
+
+	// Eligible sorts by score, which breaks ties of the other keys
+	switch m.sortKey {
+	case SortLoad:
+		sort.SliceStable(visible, func(i, j int) bool { return visible[i].Load < visible[j].Load })
+	case SortName:
+		sort.SliceStable(visible, func(i, j int) bool { return naturalLess(visible[i].Name, visible[j].Name) })
+	case SortCity:
+		sort.SliceStable(visible, func(i, j int) bool {
+			if visible[i].ExitCountry != visible[j].ExitCountry {
+				return visible[i].ExitCountry < visible[j].ExitCountry
+			}
+			return visible[i].City < visible[j].City
+		})
+	}
+	m.visible = visible
+
+	m.cursor, m.offset = 0, 0
+	for i := range m.visible {
+		if m.visible[i].ID == current {
+			m.cursor = i
+			break
+		}
+	}
+}
+
+// matches reports whether every search term occurs in the server's name, country, city or features
+func matches(server *api.LogicalServer, terms []string) bool {
+	text := strings.ToLower(strings.Join(append([]string{server.Name, server.ExitCountry, server.City}, api.GetFeatureNames(server.Features)...), " "))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// naturalLess compares names with their numbers by value, so NL#9 sorts before NL#10
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aChunk, aRest := splitChunk(a)
+		bChunk, bRest := splitChunk(b)
+		if aChunk != bChunk {
+			aNum, aErr := strconv.Atoi(aChunk)
+			bNum, bErr := strconv.Atoi(bChunk)
+			if aErr == nil && bErr == nil {
+				return aNum < bNum
+			}
+			return aChunk < bChunk
+		}
+		a, b = aRest, bRest
+	}
+	return len(a) < len(b)
+}
+
+// splitChunk splits off the leading run of digits or non-digits
+func splitChunk(s string) (string, string) {
+	digits := unicode.IsDigit(rune(s[0]))
+	for i, r := range s {
+		if unicode.IsDigit(r) != digits {
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+// handle updates the model for a key press
+func (m *Model) handle(k key) action {
+	switch k.code {
+	case keyRune:
+		m.SetQuery(m.query + string(k.r))
+	case keyBackspace:
+		if query := []rune(m.query); len(query) > 0 {
+			m.SetQuery(string(query[:len(query)-1]))
+		}
+	case keyClearSearch:
+		m.SetQuery("")
+	case keyUp:
+		m.move(-1)
+	case keyDown:
+		m.move(1)
+	case keyPageUp:
+		m.move(-pageSize)
+	case keyPageDown:
+		m.move(pageSize)
+	case keyHome:
+		m.move(-len(m.visible))
+	case keyEnd:
+		m.move(len(m.visible))
+	case keyNextSort:
+		m.SetSort((m.sortKey + 1) % SortKey(len(sortKeyNames)))
+	case keyPrevSort:
+		m.SetSort((m.sortKey + SortKey(len(sortKeyNames)) - 1) % SortKey(len(sortKeyNames)))
+	case keyToggleP2P:
+		m.toggleP2P()
+	case keyToggleSecureCore:
+		m.toggleSecureCore()
+	case keyToggleFree:
+		m.toggleFree()
+	case keyToggleCountries:
+		m.toggleCountries()
+	case keyEnter:
+		if m.Selected() != nil {
+			return actionSelect
+		}
+	case keyEscape:
+		return actionCancel
+	case keyInterrupt:
+		return actionInterrupt
+	}
+	return actionNone
+}
+
+// pageSize is the number of rows PgUp and PgDn move
+const pageSize = 10
+
+// detailRows is the maximum number of physical servers the detail pane lists
+const detailRows = 6
+
+// view renders the picker for a terminal of the given size. Lines end in "\n".
+func (m *Model) view(width, height int) string {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		text := []rune(fmt.Sprintf(format, args...))
+		if len(text) > width {
+			text = text[:width]
+		}
+		b.WriteString(string(text))
+		b.WriteString("\x1b[K\n")
+	}
+
+	countries := "all"
+	if len(m.filter.Countries) > 0 {
+		countries = strings.Join(m.filter.Countries, ",")
+	}
+	line("%d servers  sort: %s  P2P: %s  Secure Core: %s  Free: %s  countries: %s",
+		len(m.visible), m.sortKey, onOff(m.filter.P2PServersOnly), onOff(m.filter.SecureCoreOnly), onOff(m.filter.FreeOnly), countries)
+	line("Search: %s", m.query)
+	line("  %-14s %-7s %-20s %-10s %5s %6s  %s", "SERVER", "COUNTRY", "CITY", "TIER", "LOAD", "SCORE", "FEATURES")
+
+	// The header, the help line and the detail pane take the rest of the screen
+	rows := max(1, height-5-detailRows)
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+
+	for i := m.offset; i < m.offset+rows; i++ {
+		if i >= len(m.visible) {
+			line("")
+			continue
+		}
+		server := &m.visible[i]
+		row := fmt.Sprintf("%-14s %-7s %-20s %-10s %4d%% %6.2f  %s", server.Name, server.ExitCountry, truncate(server.City, 20),
+			api.GetTierName(server.Tier), server.Load, server.Score, strings.Join(api.GetFeatureNames(server.Features), ", "))
+		if i == m.cursor {
+			// Reverse video for the highlighted row
+			b.WriteString("\x1b[7m")
+			line("> %s", row)
+			b.WriteString("\x1b[0m")
+		} else {
+			line("  %s", row)
+		}
+	}
+
+	m.viewDetail(line)
+	line("↑/↓ move  type to search  ^U clear  Tab sort  ^P P2P  ^S Secure Core  ^F Free  ^A all countries  Enter generate  Esc cancel")
+
+	return b.String()
+}
+
+// viewDetail renders the physical servers of the highlighted server
+func (m *Model) viewDetail(line func(format string, args ...any)) {
+	server := m.Selected()
+	if server == nil {
+		line("No servers match")
+		for range detailRows {
+			line("")
+		}
+		return
+	}
+
+	line("%s: %d physical servers (entry %s, exit %s)", server.Name, len(server.Servers), server.EntryCountry, server.ExitCountry)
+	for i := range detailRows {
+		switch {
+		case i < len(server.Servers) && (i < detailRows-1 || len(server.Servers) == detailRows):
+			physical := &server.Servers[i]
+			status := "online"
+			if physical.Status != constants.StatusOnline {
+				status = "offline"
+			}
+			line("  %-32s entry %-15s exit %-15s %s", physical.Domain, physical.EntryIP, physical.ExitIP, status)
+		case i < len(server.Servers):
+			line("  ... and %d more", len(server.Servers)-i)
+		default:
+			line("")
+		}
+	}
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
+
+// onOff formats a toggle
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,101 @@
+package picker
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+)
+
+func testServers() []api.LogicalServer {
+	physical := []api.PhysicalServer{{Domain: "node.example.net", EntryIP: "192.0.2.1", ExitIP: "192.0.2.2", Status: 1}}
+	return []api.LogicalServer{
+		{ID: "1", Name: "NL#10", ExitCountry: "NL", City: "Amsterdam", Tier: api.TierPlus, Features: api.FeatureP2P, Score: 1.5, Load: 60, Status: 1, Servers: physical},
+		{ID: "2", Name: "NL#9", ExitCountry: "NL", City: "Rotterdam", Tier: api.TierPlus, Score: 1.2, Load: 10, Status: 1, Servers: physical},
+		{ID: "3", Name: "CH#1", ExitCountry: "CH", City: "Zurich", Tier: api.TierPlus, Features: api.FeatureSecureCore, Score: 2.0, Load: 30, Status: 1, Servers: physical},
+		{ID: "4", Name: "NL-FREE#1", ExitCountry: "NL", City: "Amsterdam", Tier: api.TierFree, Score: 3.0, Load: 90, Status: 1, Servers: physical},
+		{ID: "5", Name: "NL#11", ExitCountry: "NL", City: "Amsterdam", Tier: api.TierPlus, Score: 9.0, Load: 5, Status: 0, Servers: physical},
+	}
+}
+
+func names(servers []api.LogicalServer) string {
+	result := make([]string, 0, len(servers))
+	for i := range servers {
+		result = append(result, servers[i].Name)
+	}
+	return strings.Join(result, " ")
+}
+
+func TestModelFiltersAndSorts(t *testing.T) {
+	m := NewModel(&config.Config{Countries: []string{"NL"}}, testServers())
+
+	steps := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"eligible by score", "", "NL#10 NL#9"},
+		{"all countries", "\x01", "CH#1 NL#10 NL#9"},
+		{"sort by load", "\t", "NL#9 CH#1 NL#10"},
+		{"sort by name", "\t", "CH#1 NL#9 NL#10"},
+		{"search", "nl", "NL#9 NL#10"},
+		{"search features", " p2p", "NL#10"},
+		{"clear search", "\x15", "CH#1 NL#9 NL#10"},
+		{"p2p only", "\x10", "NL#10"},
+		{"free tier", "\x10\x06", "NL-FREE#1"},
+		{"secure core", "\x06\x13", "CH#1"},
+	}
+
+	for _, step := range steps {
+		reader := bufio.NewReader(strings.NewReader(step.keys))
+		for k, err := readKey(reader); err == nil; k, err = readKey(reader) {
+			m.handle(k)
+		}
+		if got := names(m.Visible()); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestModelNavigation(t *testing.T) {
+	m := NewModel(&config.Config{}, testServers())
+
+	for _, k := range []keyCode{keyDown, keyDown, keyDown, keyUp} {
+		m.handle(key{code: k})
+	}
+	if got := m.Selected().Name; got != "NL#10" {
+		t.Errorf("Selected %s, want NL#10", got)
+	}
+
+	// The highlighted server stays highlighted when the order changes
+	m.SetSort(SortName)
+	if got := m.Selected().Name; got != "NL#10" {
+		t.Errorf("Selected %s after sorting, want NL#10", got)
+	}
+
+	if m.handle(key{code: keyEnter}) != actionSelect {
+		t.Error("Expected Enter to select")
+	}
+	m.SetQuery("nothing matches")
+	if m.Selected() != nil || m.handle(key{code: keyEnter}) != actionNone {
+		t.Error("Expected no selection without matches")
+	}
+	if m.handle(key{code: keyEscape}) != actionCancel {
+		t.Error("Expected Esc to cancel")
+	}
+}
+
+func TestReadKeyEscapeSequences(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("\x1b[A\x1b[6~\x1b[Zé"))
+	for _, want := range []keyCode{keyUp, keyPageDown, keyPrevSort, keyRune} {
+		k, err := readKey(reader)
+		if err != nil {
+			t.Fatalf("readKey failed: %v", err)
+		}
+		if k.code != want {
+			t.Errorf("Got key %d, want %d", k.code, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package picker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/errclass"
+)
+
+// ErrCancelled is returned when the picker is closed without choosing a server
+var ErrCancelled = errclass.New(errclass.Interrupted, "no server selected")
+
+// Terminal control sequences
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	cursorHome     = "\x1b[H"
+	clearBelow     = "\x1b[J"
+)
+
+// keyResult carries the outcome of a blocking key read
+type keyResult struct {
+	key key
+	err error
+}
+
+// Run shows the picker on the terminal and returns the server chosen with Enter. Esc returns
+// ErrCancelled and Ctrl-C returns context.Canceled, as it would outside of raw mode.
+func Run(ctx context.Context, cfg *config.Config, servers []api.LogicalServer) (*api.LogicalServer, error) {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return nil, fmt.Errorf("-interactive requires a terminal")
+	}
+
+	model := NewModel(cfg, servers)
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	defer func() { _ = term.Restore(in, state) }()
+
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(leaveAltScreen)
+
+	// Keys are read one at a time on request, so no read is left pending once a server is chosen
+	reader := bufio.NewReader(os.Stdin)
+	next := make(chan struct{})
+	keys := make(chan keyResult, 1)
+	go func() {
+		for range next {
+			k, err := readKey(reader)
+			keys <- keyResult{key: k, err: err}
+		}
+	}()
+	defer close(next)
+
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		// Raw mode doesn't translate newlines
+		fmt.Print(cursorHome + strings.ReplaceAll(model.view(width, height), "\n", "\r\n") + clearBelow)
+
+		next <- struct{}{}
+		var result keyResult
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result = <-keys:
+		}
+		if result.err != nil {
+			return nil, fmt.Errorf("failed to read from the terminal: %w", result.err)
+		}
+
+		switch model.handle(result.key) {
+		case actionSelect:
+			server := *model.Selected()
+			return &server, nil
+		case actionCancel:
+			return nil, ErrCancelled
+		case actionInterrupt:
+			return nil, context.Canceled
+		}
+	}
+}
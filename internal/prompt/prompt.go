@@ -0,0 +1,85 @@
+// Package prompt provides cancellable interactive terminal prompts.
+package prompt
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdin is shared by all prompts so that buffered input is not lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptResult carries the outcome of a blocking terminal read
+type promptResult struct {
+	value string
+	err   error
+}
+
+// ReadLine prints the prompt and reads a trimmed line from stdin.
+// It returns early with the context's error if the context is cancelled.
+func ReadLine(ctx context.Context, prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	result := make(chan promptResult, 1)
+	go func() {
+		line, err := stdin.ReadString('\n')
+		result <- promptResult{value: strings.TrimSpace(line), err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		fmt.Println()
+		return "", ctx.Err()
+	case r := <-result:
+		return r.value, r.err
+	}
+}
+
+// ReadPassword prints the prompt and reads a line from the terminal without echo.
+// If the context is cancelled, the terminal state is restored before returning.
+func ReadPassword(ctx context.Context, prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Print(prompt)
+
+	result := make(chan promptResult, 1)
+	go func() {
+		password, err := term.ReadPassword(fd)
+		result <- promptResult{value: string(password), err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = term.Restore(fd, state)
+		fmt.Println()
+		return "", ctx.Err()
+	case r := <-result:
+		fmt.Println()
+		return r.value, r.err
+	}
+}
+
+// Confirm asks a yes/no question and reports whether the answer was yes. Anything
+// other than "y" or "yes" counts as no.
+func Confirm(ctx context.Context, question string) (bool, error) {
+	answer, err := ReadLine(ctx, question+" [y/N]: ")
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
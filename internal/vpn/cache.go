@@ -0,0 +1,69 @@
+package vpn
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/pkg/fileutil"
+)
+
+// CachedServer is the part of a logical server kept in the server cache. The cache lets shell
+// completion suggest countries, cities and server names without authenticating.
+type CachedServer struct {
+	Name        string `json:"name"`
+	ExitCountry string `json:"exit_country"`
+	City        string `json:"city,omitempty"`
+}
+
+// ServerCachePath returns $XDG_CACHE_HOME/protonvpn-wg/servers.json, falling back to ~/.cache
+func ServerCachePath() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			homeDir = "."
+		}
+		dir = filepath.Join(homeDir, ".cache")
+	}
+	return filepath.Join(dir, constants.CacheDirName, constants.ServerCacheFileName)
+}
+
+// LoadServerCache reads the servers cached by the last server list fetch
+func LoadServerCache() ([]CachedServer, error) {
+	data, err := os.ReadFile(ServerCachePath())
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []CachedServer
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("failed to parse server cache: %w", err)
+	}
+	return servers, nil
+}
+
+// SaveServerCache replaces the server cache. The cache is a convenience, so failures are only
+// logged.
+func SaveServerCache(servers []api.LogicalServer) {
+	cached := make([]CachedServer, 0, len(servers))
+	for i := range servers {
+		cached = append(cached, CachedServer{Name: servers[i].Name, ExitCountry: servers[i].ExitCountry, City: servers[i].City})
+	}
+
+	path := ServerCachePath()
+	data, err := json.Marshal(cached)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755) //nolint:gosec // the cache holds public server names
+	}
+	if err == nil {
+		err = fileutil.WriteFileAtomic(path, data, 0o644) //nolint:gosec // the cache holds public server names
+	}
+	if err != nil {
+		slog.Debug("Failed to save server cache", "path", path, "error", err)
+	}
+}
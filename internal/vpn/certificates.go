@@ -0,0 +1,119 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+// certificatesPageSize is the number of certificates requested per page
+const certificatesPageSize = 50
+
+// minDeviceNameTimestamp rejects trailing numbers in device names that can't be creation timestamps
+const minDeviceNameTimestamp = 1_000_000_000
+
+// ListCertificates fetches all persistent certificates (devices) of the account
+func (c *Client) ListCertificates(ctx context.Context) ([]api.VPNInfo, error) {
+	var certificates []api.VPNInfo
+
+	for offset := 0; ; offset += certificatesPageSize {
+		query := url.Values{}
+		query.Set("Mode", constants.CertMode)
+		query.Set("Offset", strconv.Itoa(offset))
+		query.Set("Limit", strconv.Itoa(certificatesPageSize))
+
+		var response api.CertificatesResponse
+		if err := c.api.Do(ctx, http.MethodGet, constants.CertificatesPath+"?"+query.Encode(), nil, &response); err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, response.Certificates...)
+		if len(response.Certificates) < certificatesPageSize {
+			return certificates, nil
+		}
+	}
+}
+
+// RevokeCertificate revokes a certificate, removing the device from the dashboard
+func (c *Client) RevokeCertificate(ctx context.Context, certificate *api.VPNInfo) error {
+	reqBody := map[string]interface{}{
+		"ClientPublicKey": certificate.ClientKey,
+	}
+
+	if err := c.api.Do(ctx, http.MethodDelete, constants.CertificatePath, reqBody, nil); err != nil {
+		return fmt.Errorf("failed to revoke certificate %s: %w", certificate.SerialNumber, err)
+	}
+	return nil
+}
+
+// MatchCertificates returns the certificates matching any of the targets (a serial number or a
+// device-name glob) or created longer ago than olderThan (if non-zero)
+func MatchCertificates(certificates []api.VPNInfo, targets []string, olderThan time.Duration, now time.Time) []api.VPNInfo {
+	var matched []api.VPNInfo
+
+	for i := range certificates {
+		if matchesTarget(&certificates[i], targets) || isOlderThan(&certificates[i], olderThan, now) {
+			matched = append(matched, certificates[i])
+		}
+	}
+
+	return matched
+}
+
+// CountUnknownAge returns how many certificates no target matches and whose age -older-than
+// can't tell, because their device names don't end in a creation time
+func CountUnknownAge(certificates []api.VPNInfo, targets []string) int {
+	unknown := 0
+	for i := range certificates {
+		if _, ok := DeviceCreatedAt(certificates[i].DeviceName); !ok && !matchesTarget(&certificates[i], targets) {
+			unknown++
+		}
+	}
+	return unknown
+}
+
+func matchesTarget(certificate *api.VPNInfo, targets []string) bool {
+	for _, target := range targets {
+		if certificate.SerialNumber == target {
+			return true
+		}
+		if ok, err := path.Match(target, certificate.DeviceName); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isOlderThan(certificate *api.VPNInfo, olderThan time.Duration, now time.Time) bool {
+	if olderThan <= 0 {
+		return false
+	}
+
+	createdAt, ok := DeviceCreatedAt(certificate.DeviceName)
+	return ok && now.Sub(createdAt) > olderThan
+}
+
+// DeviceCreatedAt extracts the creation time from auto-generated device names
+// (WireGuard-<user>-<unix>). The API doesn't report when a certificate was created,
+// so devices with custom names have no known creation time.
+func DeviceCreatedAt(deviceName string) (time.Time, bool) {
+	idx := strings.LastIndex(deviceName, "-")
+	if idx < 0 {
+		return time.Time{}, false
+	}
+
+	unix, err := strconv.ParseInt(deviceName[idx+1:], 10, 64)
+	if err != nil || unix < minDeviceNameTimestamp {
+		return time.Time{}, false
+	}
+
+	return time.Unix(unix, 0), true
+}
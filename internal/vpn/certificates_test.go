@@ -0,0 +1,54 @@
+package vpn
+
+import (
+	"testing"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+)
+
+func TestMatchCertificates(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	certificates := []api.VPNInfo{
+		{SerialNumber: "1001", DeviceName: "WireGuard-alice-1690000000"}, // ~115 days old
+		{SerialNumber: "1002", DeviceName: "WireGuard-alice-1699990000"}, // ~3 hours old
+		{SerialNumber: "1003", DeviceName: "office-router"},
+		{SerialNumber: "1004", DeviceName: "office-2"},
+	}
+
+	tests := []struct {
+		name      string
+		targets   []string
+		olderThan time.Duration
+		want      []string
+	}{
+		{"serial", []string{"1003"}, 0, []string{"1003"}},
+		{"glob", []string{"office-*"}, 0, []string{"1003", "1004"}},
+		{"older than", nil, 30 * 24 * time.Hour, []string{"1001"}},
+		{"glob or older than", []string{"office-router"}, 24 * time.Hour, []string{"1001", "1003"}},
+		{"no match", []string{"home-*"}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched := MatchCertificates(certificates, tt.targets, tt.olderThan, now)
+
+			var got []string
+			for i := range matched {
+				got = append(got, matched[i].SerialNumber)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	if got := CountUnknownAge(certificates, []string{"office-router"}); got != 1 {
+		t.Errorf("Expected 1 certificate of unknown age, got %d", got)
+	}
+}
@@ -2,10 +2,8 @@
 package vpn
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
@@ -13,41 +11,39 @@ import (
 	"protonvpn-wg-config-generate/internal/config"
 	"protonvpn-wg-config-generate/internal/constants"
 	"protonvpn-wg-config-generate/pkg/timeutil"
-
-	"github.com/ProtonVPN/go-vpn-lib/ed25519"
 )
 
 // Client handles VPN operations
 type Client struct {
-	config     *config.Config
-	session    *api.Session
-	httpClient *http.Client
+	config *config.Config
+	api    *api.Client
 }
 
-// NewClient creates a new VPN client
-func NewClient(cfg *config.Config, session *api.Session) *Client {
+// NewClient creates a new VPN client using an authenticated API client
+func NewClient(cfg *config.Config, apiClient *api.Client) *Client {
 	return &Client{
-		config:     cfg,
-		session:    session,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		config: cfg,
+		api:    apiClient,
 	}
 }
 
-// GetCertificate generates a VPN certificate
-func (c *Client) GetCertificate(keyPair *ed25519.KeyPair) (*api.VPNInfo, error) {
-	publicKeyPEM, err := keyPair.PublicKeyPKIXPem()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get public key PEM: %w", err)
-	}
-
+// GetCertificate requests a VPN certificate for the given Ed25519 public key (PKIX PEM).
+// Requesting a certificate for a key that already has one renews it.
+func (c *Client) GetCertificate(ctx context.Context, publicKeyPEM string) (*api.VPNInfo, error) {
 	// Use provided device name or generate one
 	deviceName := c.config.DeviceName
 	if deviceName == "" {
 		deviceName = fmt.Sprintf("WireGuard-%s-%d", c.config.Username, time.Now().Unix())
 	}
 
-	// Parse duration
-	durationStr, err := timeutil.ParseToMinutes(c.config.Duration)
+	// Parse duration or absolute expiry
+	var durationStr string
+	var err error
+	if c.config.ValidUntil != "" {
+		durationStr, err = timeutil.ValidUntilToMinutes(c.config.ValidUntil, time.Now())
+	} else {
+		durationStr, err = timeutil.ParseToMinutes(c.config.Duration)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse duration: %w", err)
 	}
@@ -58,90 +54,36 @@ func (c *Client) GetCertificate(keyPair *ed25519.KeyPair) (*api.VPNInfo, error)
 		"Mode":                "persistent", // Create persistent configuration
 		"DeviceName":          deviceName,
 		"Duration":            durationStr,
-		"Features": map[string]interface{}{
-			"netshield-level": 0,
-			"moderate-nat":    false,
-			"port-forwarding": false,
-			"vpn-accelerator": c.config.EnableAccelerator,
-			"bouncing":        true,
-		},
-	}
-
-	certJSON, err := json.Marshal(certReq)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, c.config.APIURL+"/vpn/v1/certificate", bytes.NewBuffer(certJSON))
-	if err != nil {
-		return nil, err
-	}
-
-	c.setHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		"Features":            RequestedFeatures(c.config),
 	}
 
+	// Not retried: if the certificate was created and only the response was lost, a retry would
+	// register a second device
 	var vpnInfo api.VPNInfo
-	if err := json.Unmarshal(body, &vpnInfo); err != nil {
+	if err := c.api.Do(ctx, http.MethodPost, constants.CertificatePath, certReq, &vpnInfo, api.WithoutRetry()); err != nil {
 		return nil, err
 	}
 
-	if vpnInfo.Code != constants.APICodeSuccess {
-		// Include the actual API error message if available
-		if vpnInfo.Error != "" {
-			return nil, fmt.Errorf("VPN certificate error (code %d): %s", vpnInfo.Code, vpnInfo.Error)
-		}
-		return nil, fmt.Errorf("failed to get VPN certificate, code: %d", vpnInfo.Code)
-	}
-
 	return &vpnInfo, nil
 }
 
-// GetServers fetches the list of VPN servers
-func (c *Client) GetServers() ([]api.LogicalServer, error) {
-	req, err := http.NewRequest(http.MethodGet, c.config.APIURL+"/vpn/v1/logicals", http.NoBody)
-	if err != nil {
-		return nil, err
-	}
-
-	c.setHeaders(req)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// RequestedFeatures returns the certificate features the configuration requests
+func RequestedFeatures(cfg *config.Config) api.CertificateFeatures {
+	return api.CertificateFeatures{
+		NetshieldLevel: cfg.NetShieldLevel,
+		ModerateNAT:    cfg.ModerateNAT,
+		PortForwarding: cfg.PortForwarding,
+		VPNAccelerator: cfg.EnableAccelerator,
+		Bouncing:       true,
 	}
+}
 
+// GetServers fetches the list of VPN servers
+func (c *Client) GetServers(ctx context.Context) ([]api.LogicalServer, error) {
 	var response api.LogicalsResponse
-	if err := json.Unmarshal(body, &response); err != nil {
+	if err := c.api.Do(ctx, http.MethodGet, constants.LogicalsPath, nil, &response); err != nil {
 		return nil, err
 	}
 
-	if response.Code != constants.APICodeSuccess {
-		return nil, fmt.Errorf("API returned error code: %d", response.Code)
-	}
-
 	return response.LogicalServers, nil
 }
-
-func (c *Client) setHeaders(req *http.Request) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.session.AccessToken))
-	req.Header.Set("x-pm-uid", c.session.UID)
-	req.Header.Set("x-pm-appversion", constants.AppVersion)
-	req.Header.Set("User-Agent", constants.UserAgent)
-}
@@ -0,0 +1,33 @@
+package vpn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+	"protonvpn-wg-config-generate/internal/constants"
+)
+
+func TestGetCertificateIsNotRetried(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == constants.CertificatePath {
+			posts++
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	apiClient := api.NewClient(server.URL, api.WithRetries(3), api.WithTimeout(10*time.Second))
+	cfg := &config.Config{Username: "alice", DeviceName: "laptop", Duration: "1d"}
+	if _, err := NewClient(cfg, apiClient).GetCertificate(context.Background(), "key"); err == nil {
+		t.Fatal("Expected an error for a 502 response")
+	}
+	if posts != 1 {
+		t.Errorf("Expected the certificate request to be sent once, got %d", posts)
+	}
+}
@@ -0,0 +1,103 @@
+package vpn
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/pkg/keys"
+)
+
+// DeviceNameData holds the values available to device name templates
+type DeviceNameData struct {
+	Hostname string // Short host name of this machine
+	Username string
+	Country  string // Exit country code of the selected server
+	City     string
+	Server   string // Logical server name (e.g., NL#42)
+	Date     string // YYYY-MM-DD
+	Unix     int64
+}
+
+// NewDeviceNameData collects the template values for the selected server
+func NewDeviceNameData(server *api.LogicalServer, username string, now time.Time) DeviceNameData {
+	hostname, _ := os.Hostname()
+	hostname, _, _ = strings.Cut(hostname, ".")
+
+	return DeviceNameData{
+		Hostname: hostname,
+		Username: username,
+		Country:  server.ExitCountry,
+		City:     server.City,
+		Server:   server.Name,
+		Date:     now.Format("2006-01-02"),
+		Unix:     now.Unix(),
+	}
+}
+
+// IsDeviceNameTemplate reports whether a device name contains template actions
+func IsDeviceNameTemplate(name string) bool {
+	return strings.Contains(name, "{{")
+}
+
+// ExpandDeviceName expands a device name template such as {{.Hostname}}-{{.Country}}-{{.Date}}.
+// Names without template actions are returned unchanged.
+func ExpandDeviceName(name string, data DeviceNameData) (string, error) {
+	if !IsDeviceNameTemplate(name) {
+		return name, nil
+	}
+
+	tmpl, err := template.New("device-name").Option("missingkey=error").Parse(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid device name template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid device name template: %w", err)
+	}
+
+	expanded := strings.Join(strings.Fields(buf.String()), "-")
+	if expanded == "" {
+		return "", fmt.Errorf("device name template %q expands to an empty name", name)
+	}
+	return expanded, nil
+}
+
+// DeviceNameCollisions returns the certificates named deviceName that belong to a different key
+// than publicKey. A certificate of the same key is renewed, not duplicated, so it doesn't collide.
+func DeviceNameCollisions(certificates []api.VPNInfo, deviceName string, publicKey ed25519.PublicKey) []api.VPNInfo {
+	var collisions []api.VPNInfo
+	for i := range certificates {
+		if certificates[i].DeviceName != deviceName {
+			continue
+		}
+		if certificateKey, err := keys.ParsePublicKey(certificates[i].ClientKey); err == nil && certificateKey.Equal(publicKey) {
+			continue
+		}
+		collisions = append(collisions, certificates[i])
+	}
+	return collisions
+}
+
+// UniqueDeviceName returns deviceName with the lowest numeric suffix (-2, -3, ...) that no
+// certificate uses yet and that isn't reserved
+func UniqueDeviceName(certificates []api.VPNInfo, deviceName string, reserved map[string]bool) string {
+	used := make(map[string]bool, len(certificates))
+	for i := range certificates {
+		used[certificates[i].DeviceName] = true
+	}
+
+	for n := 2; ; n++ {
+		candidate := deviceName + "-" + strconv.Itoa(n)
+		if !used[candidate] && !reserved[candidate] {
+			return candidate
+		}
+	}
+}
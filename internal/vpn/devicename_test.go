@@ -0,0 +1,75 @@
+package vpn
+
+import (
+	"testing"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/pkg/keys"
+)
+
+func TestExpandDeviceName(t *testing.T) {
+	data := DeviceNameData{Hostname: "htpc", Username: "alice", Country: "NL", City: "Amsterdam", Server: "NL#42", Date: "2026-10-18", Unix: 1_792_000_000}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"plain name", "office-router", "office-router", false},
+		{"template", "{{.Hostname}}-{{.Country}}-{{.Date}}", "htpc-NL-2026-10-18", false},
+		{"spaces collapsed", "{{.City}} {{.Server}}", "Amsterdam-NL#42", false},
+		{"unix suffix", "{{.Username}}-{{.Unix}}", "alice-1792000000", false},
+		{"unknown field", "{{.Region}}", "", true},
+		{"syntax error", "{{.Hostname", "", true},
+		{"empty result", "{{if false}}x{{end}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandDeviceName(tt.input, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandDeviceName(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandDeviceName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceNameCollisions(t *testing.T) {
+	own, err := keys.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	other, err := keys.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	ownPEM, _ := own.PublicKeyPEM()
+	otherPEM, _ := other.PublicKeyPEM()
+	ownKey, _ := own.PublicKey()
+
+	certificates := []api.VPNInfo{
+		{SerialNumber: "1001", DeviceName: "router", ClientKey: ownPEM},
+		{SerialNumber: "1002", DeviceName: "router", ClientKey: otherPEM},
+		{SerialNumber: "1003", DeviceName: "router-2", ClientKey: otherPEM},
+		{SerialNumber: "1004", DeviceName: "laptop", ClientKey: otherPEM},
+	}
+
+	collisions := DeviceNameCollisions(certificates, "router", ownKey)
+	if len(collisions) != 1 || collisions[0].SerialNumber != "1002" {
+		t.Errorf("Expected collision with 1002 only, got %+v", collisions)
+	}
+	if collisions := DeviceNameCollisions(certificates, "desktop", ownKey); len(collisions) != 0 {
+		t.Errorf("Expected no collisions, got %+v", collisions)
+	}
+
+	if got := UniqueDeviceName(certificates, "router", nil); got != "router-3" {
+		t.Errorf("UniqueDeviceName = %q, want router-3", got)
+	}
+	if got := UniqueDeviceName(certificates, "router", map[string]bool{"router-3": true}); got != "router-4" {
+		t.Errorf("UniqueDeviceName with router-3 reserved = %q, want router-4", got)
+	}
+}
@@ -1,14 +1,15 @@
 package vpn
 
 import (
-	"errors"
 	"fmt"
+	"log/slog"
 	"sort"
 	"strings"
 
 	"protonvpn-wg-config-generate/internal/api"
 	"protonvpn-wg-config-generate/internal/config"
 	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
 )
 
 // ServerSelector handles server selection logic
@@ -23,16 +24,21 @@ func NewServerSelector(cfg *config.Config) *ServerSelector {
 
 // SelectBest selects the best server based on configuration
 func (s *ServerSelector) SelectBest(servers []api.LogicalServer) (*api.LogicalServer, error) {
-	filtered := s.filterServers(servers)
+	filtered := s.Eligible(servers)
 
-	if s.config.Debug {
-		s.printDebugServerList(filtered)
-	}
+	logServerList(filtered)
 
 	if len(filtered) == 0 {
 		return nil, s.buildNoServersError()
 	}
 
+	return &filtered[0], nil
+}
+
+// Eligible returns the servers matching the configuration, best first
+func (s *ServerSelector) Eligible(servers []api.LogicalServer) []api.LogicalServer {
+	filtered := s.filterServers(servers)
+
 	// Sort servers: first by score (descending), then by load (ascending)
 	sort.Slice(filtered, func(i, j int) bool {
 		// If scores are different, higher score wins
@@ -43,7 +49,7 @@ func (s *ServerSelector) SelectBest(servers []api.LogicalServer) (*api.LogicalSe
 		return filtered[i].Load < filtered[j].Load
 	})
 
-	return &filtered[0], nil
+	return filtered
 }
 
 func (s *ServerSelector) filterServers(servers []api.LogicalServer) []api.LogicalServer {
@@ -82,13 +88,18 @@ func (s *ServerSelector) isServerEligible(server *api.LogicalServer) bool {
 		return false
 	}
 
+	// Port forwarding only works on P2P servers
+	if s.config.PortForwarding && server.Features&api.FeatureP2P == 0 {
+		return false
+	}
+
 	// Filter by Secure Core if requested
 	if s.config.SecureCoreOnly && server.Features&api.FeatureSecureCore == 0 {
 		return false
 	}
 
-	// Filter by country
-	if !s.isCountryMatch(server) {
+	// Filter by country, city and server name
+	if !s.isCountryMatch(server) || !matchesAny(s.config.Cities, server.City) || !matchesAny(s.config.Servers, server.Name) {
 		return false
 	}
 
@@ -100,7 +111,11 @@ func (s *ServerSelector) isServerEligible(server *api.LogicalServer) bool {
 	return true
 }
 
+// isCountryMatch matches any country if none are configured (servers lists all countries)
 func (s *ServerSelector) isCountryMatch(server *api.LogicalServer) bool {
+	if len(s.config.Countries) == 0 {
+		return true
+	}
 	for _, country := range s.config.Countries {
 		if server.ExitCountry == country {
 			return true
@@ -109,16 +124,46 @@ func (s *ServerSelector) isCountryMatch(server *api.LogicalServer) bool {
 	return false
 }
 
+// matchesAny matches a value against a list case-insensitively; an empty list matches any value
+func matchesAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ServerSelector) buildNoServersError() error {
 	errMsg := fmt.Sprintf("No suitable servers found for countries: %v", s.config.Countries)
+	if len(s.config.Cities) > 0 {
+		errMsg += fmt.Sprintf(", cities: %v", s.config.Cities)
+	}
+	if len(s.config.Servers) > 0 {
+		errMsg += fmt.Sprintf(", servers: %v", s.config.Servers)
+	}
 
 	if s.config.SecureCoreOnly {
 		errMsg += " with Secure Core"
-	} else if s.config.P2PServersOnly {
+		if s.config.PortForwarding {
+			errMsg += " and P2P support (required for port forwarding)"
+		}
+	} else if s.config.P2PServersOnly || s.config.PortForwarding {
 		errMsg += " with P2P support"
 	}
 
-	return errors.New(errMsg)
+	return errclass.New(errclass.NoServers, "%s", errMsg)
+}
+
+// ValidateServerFeatures checks that a server supports the requested certificate features
+func ValidateServerFeatures(cfg *config.Config, serverFeatures int) error {
+	if cfg.PortForwarding && serverFeatures&api.FeatureP2P == 0 {
+		return errclass.New(errclass.InvalidInput, "port forwarding requires a P2P server")
+	}
+	return nil
 }
 
 // GetBestPhysicalServer returns the best physical server from a logical server
@@ -138,28 +183,16 @@ func GetBestPhysicalServer(server *api.LogicalServer) *api.PhysicalServer {
 	return &server.Servers[0]
 }
 
-// printDebugServerList prints a debug list of filtered servers
-func (s *ServerSelector) printDebugServerList(servers []api.LogicalServer) {
-	fmt.Printf("\nDEBUG: Found %d servers after filtering:\n", len(servers))
-	fmt.Println("==================================================================================")
-	fmt.Printf("%-15s | %-18s | %-12s | Load | Score | Features\n", "Server", "City", "Tier")
-	fmt.Println("----------------------------------------------------------------------------------")
-
+// logServerList logs the filtered servers at debug level
+func logServerList(servers []api.LogicalServer) {
+	slog.Debug("Servers after filtering", "count", len(servers))
 	for i := range servers {
-		features := api.GetFeatureNames(servers[i].Features)
-		featureStr := "-"
-		if len(features) > 0 {
-			featureStr = strings.Join(features, ", ")
-		}
-
-		fmt.Printf("%-15s | %-18s | %-12s | %3d%% | %.2f | %s\n",
-			servers[i].Name,
-			servers[i].City,
-			api.GetTierName(servers[i].Tier),
-			servers[i].Load,
-			servers[i].Score,
-			featureStr)
+		slog.Debug("Eligible server",
+			"server", servers[i].Name,
+			"city", servers[i].City,
+			"tier", api.GetTierName(servers[i].Tier),
+			"load", servers[i].Load,
+			"score", servers[i].Score,
+			"features", strings.Join(api.GetFeatureNames(servers[i].Features), ","))
 	}
-
-	fmt.Println("==================================================================================")
 }
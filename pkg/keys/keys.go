@@ -0,0 +1,151 @@
+// Package keys handles ProtonVPN client key pairs.
+//
+// A client key is an Ed25519 key whose clamped secret scalar doubles as the WireGuard
+// (X25519) private key. Since the Ed25519 public key is that scalar times the base point,
+// it can be recomputed from the WireGuard private key alone, which allows requesting new
+// certificates for an existing WireGuard key. Conversely, a device that keeps its private key
+// only needs to hand out the Ed25519 public key, from which its WireGuard public key follows.
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"filippo.io/edwards25519"
+	vpned25519 "github.com/ProtonVPN/go-vpn-lib/ed25519"
+)
+
+// KeySize is the size of a WireGuard key in bytes
+const KeySize = 32
+
+// KeyPair is a client key pair identified by its WireGuard private key. For keys generated on
+// another device, only the public half is known.
+type KeyPair struct {
+	private []byte
+	public  ed25519.PublicKey
+}
+
+// Generate creates a new random client key pair
+func Generate() (*KeyPair, error) {
+	keyPair, err := vpned25519.NewKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	defer keyPair.Clear()
+
+	return &KeyPair{private: keyPair.ToX25519()}, nil
+}
+
+// ParsePrivateKey parses a base64 WireGuard private key as found in a config file
+func ParsePrivateKey(encoded string) (*KeyPair, error) {
+	private, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key encoding: %w", err)
+	}
+	if len(private) != KeySize {
+		return nil, fmt.Errorf("invalid private key length: %d bytes (expected %d)", len(private), KeySize)
+	}
+
+	return &KeyPair{private: private}, nil
+}
+
+// FromPublicKey creates a key pair of which only the Ed25519 public key is known
+func FromPublicKey(publicKey ed25519.PublicKey) *KeyPair {
+	return &KeyPair{public: publicKey}
+}
+
+// ParsePublicKey parses an Ed25519 public key in PKIX PEM format or as base64 of the raw 32 bytes
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	if block, _ := pem.Decode([]byte(encoded)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		publicKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is %T, not Ed25519", parsed)
+		}
+		return publicKey, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: expected PEM or base64")
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length: %d bytes (expected %d)", len(raw), ed25519.PublicKeySize)
+	}
+	// Reject bytes that aren't a curve point, such as an X25519 key of the wrong size or type
+	if _, err := new(edwards25519.Point).SetBytes(raw); err != nil {
+		return nil, fmt.Errorf("invalid Ed25519 public key: %w", err)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// HasPrivateKey reports whether the private key is known
+func (k *KeyPair) HasPrivateKey() bool {
+	return k.private != nil
+}
+
+// PrivateKey returns the base64 WireGuard private key, or "" if only the public key is known
+func (k *KeyPair) PrivateKey() string {
+	if k.private == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(k.private)
+}
+
+// WireGuardPublicKey returns the base64 WireGuard (X25519) public key, the Montgomery form
+// of the Ed25519 public key
+func (k *KeyPair) WireGuardPublicKey() (string, error) {
+	publicKey, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	point, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(point.BytesMontgomery()), nil
+}
+
+// PublicKey returns the Ed25519 public key used to request certificates
+func (k *KeyPair) PublicKey() (ed25519.PublicKey, error) {
+	if k.private == nil {
+		return k.public, nil
+	}
+
+	scalar, err := edwards25519.NewScalar().SetBytesWithClamping(k.private)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	point := new(edwards25519.Point).ScalarBaseMult(scalar)
+	return ed25519.PublicKey(point.Bytes()), nil
+}
+
+// PublicKeyPEM returns the Ed25519 public key in PKIX PEM format, as expected by the certificate API
+func (k *KeyPair) PublicKeyPEM() (string, error) {
+	publicKey, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	return EncodePublicKeyPEM(publicKey)
+}
+
+// EncodePublicKeyPEM encodes an Ed25519 public key in PKIX PEM format
+func EncodePublicKeyPEM(publicKey ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
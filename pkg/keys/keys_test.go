@@ -0,0 +1,85 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/base64"
+	"testing"
+
+	vpned25519 "github.com/ProtonVPN/go-vpn-lib/ed25519"
+)
+
+func TestPublicKeyFromWireGuardPrivateKey(t *testing.T) {
+	for range 10 {
+		original, err := vpned25519.NewKeyPair()
+		if err != nil {
+			t.Fatalf("NewKeyPair failed: %v", err)
+		}
+
+		keyPair, err := ParsePrivateKey(original.ToX25519Base64())
+		if err != nil {
+			t.Fatalf("ParsePrivateKey failed: %v", err)
+		}
+
+		publicKey, err := keyPair.PublicKey()
+		if err != nil {
+			t.Fatalf("PublicKey failed: %v", err)
+		}
+		if !bytes.Equal(publicKey, original.PublicKeyBytes()) {
+			t.Fatalf("Derived public key %x doesn't match original %x", publicKey, original.PublicKeyBytes())
+		}
+
+		expectedPEM, _ := original.PublicKeyPKIXPem()
+		derivedPEM, err := keyPair.PublicKeyPEM()
+		if err != nil || derivedPEM != expectedPEM {
+			t.Fatalf("Derived PEM doesn't match original:\n%s\n%s", derivedPEM, expectedPEM)
+		}
+	}
+}
+
+func TestParsePrivateKeyInvalid(t *testing.T) {
+	for _, input := range []string{"", "not base64!", "dG9vIHNob3J0"} {
+		if _, err := ParsePrivateKey(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestPublicKeyOnly(t *testing.T) {
+	original, err := vpned25519.NewKeyPair()
+	if err != nil {
+		t.Fatalf("NewKeyPair failed: %v", err)
+	}
+	full, _ := ParsePrivateKey(original.ToX25519Base64())
+
+	// The WireGuard public key must match the X25519 public key of the WireGuard private key
+	privateKey, err := ecdh.X25519().NewPrivateKey(original.ToX25519())
+	if err != nil {
+		t.Fatalf("NewPrivateKey failed: %v", err)
+	}
+	expectedWG := base64.StdEncoding.EncodeToString(privateKey.PublicKey().Bytes())
+
+	pemKey, _ := original.PublicKeyPKIXPem()
+	for _, encoded := range []string{pemKey, base64.StdEncoding.EncodeToString(original.PublicKeyBytes())} {
+		publicKey, err := ParsePublicKey(encoded)
+		if err != nil {
+			t.Fatalf("ParsePublicKey failed: %v", err)
+		}
+
+		keyPair := FromPublicKey(publicKey)
+		if keyPair.HasPrivateKey() || keyPair.PrivateKey() != "" {
+			t.Error("Expected public-only key pair")
+		}
+
+		for _, k := range []*KeyPair{keyPair, full} {
+			wgPublic, err := k.WireGuardPublicKey()
+			if err != nil || wgPublic != expectedWG {
+				t.Errorf("WireGuardPublicKey = %q (%v), expected %q", wgPublic, err, expectedWG)
+			}
+		}
+
+		if derivedPEM, _ := keyPair.PublicKeyPEM(); derivedPEM != pemKey {
+			t.Errorf("PEM round trip mismatch:\n%s\n%s", derivedPEM, pemKey)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package natpmp implements the client side of NAT Port Mapping Protocol (RFC 6886), which
+// ProtonVPN gateways use to assign forwarded ports.
+package natpmp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// Port is the UDP port NAT-PMP gateways listen on
+const Port = 5351
+
+// Protocol versions and opcodes (RFC 6886 section 3)
+const (
+	version          = 0
+	opExternalAddr   = 0
+	opMapUDP         = 1
+	opMapTCP         = 2
+	opResponseOffset = 128
+)
+
+// Retransmission schedule: the first attempt waits 250ms, doubling on each retry (RFC 6886 section 3.1)
+const (
+	initialTimeout     = 250 * time.Millisecond
+	defaultMaxAttempts = 9
+)
+
+// Protocol is a transport protocol that can be mapped
+type Protocol uint8
+
+// Mappable protocols
+const (
+	UDP Protocol = opMapUDP
+	TCP Protocol = opMapTCP
+)
+
+// String returns the lowercase protocol name
+func (p Protocol) String() string {
+	switch p {
+	case UDP:
+		return "udp"
+	case TCP:
+		return "tcp"
+	default:
+		return fmt.Sprintf("protocol(%d)", uint8(p))
+	}
+}
+
+// ResultError is a non-success result code returned by the gateway
+type ResultError struct {
+	Code uint16
+}
+
+// Error returns a description of the result code
+func (e *ResultError) Error() string {
+	switch e.Code {
+	case 1:
+		return "NAT-PMP: unsupported version"
+	case 2:
+		return "NAT-PMP: not authorized/refused"
+	case 3:
+		return "NAT-PMP: network failure"
+	case 4:
+		return "NAT-PMP: out of resources"
+	case 5:
+		return "NAT-PMP: unsupported opcode"
+	default:
+		return fmt.Sprintf("NAT-PMP: result code %d", e.Code)
+	}
+}
+
+// Mapping is a port mapping granted by the gateway
+type Mapping struct {
+	Protocol     Protocol
+	InternalPort uint16
+	ExternalPort uint16
+	Lifetime     time.Duration
+	Epoch        uint32 // Seconds since the gateway's mapping table was initialized
+}
+
+// Client sends NAT-PMP requests to a gateway
+type Client struct {
+	addr        string
+	maxAttempts int
+}
+
+// NewClient creates a client for the gateway at addr (host or host:port)
+func NewClient(addr string) *Client {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, fmt.Sprint(Port))
+	}
+	return &Client{addr: addr, maxAttempts: defaultMaxAttempts}
+}
+
+// ExternalAddress requests the gateway's public IPv4 address
+func (c *Client) ExternalAddress(ctx context.Context) (netip.Addr, error) {
+	response, err := c.call(ctx, []byte{version, opExternalAddr}, opExternalAddr, 12)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.AddrFrom4([4]byte(response[8:12])), nil
+}
+
+// AddPortMapping requests a mapping of internalPort to a public port, preferably externalPort
+// (0 lets the gateway choose). A lifetime of 0 deletes the mapping.
+func (c *Client) AddPortMapping(ctx context.Context, protocol Protocol, internalPort, externalPort uint16, lifetime time.Duration) (*Mapping, error) {
+	request := make([]byte, 12)
+	request[0] = version
+	request[1] = byte(protocol)
+	binary.BigEndian.PutUint16(request[4:6], internalPort)
+	binary.BigEndian.PutUint16(request[6:8], externalPort)
+	binary.BigEndian.PutUint32(request[8:12], uint32(lifetime/time.Second))
+
+	response, err := c.call(ctx, request, byte(protocol), 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mapping{
+		Protocol:     protocol,
+		Epoch:        binary.BigEndian.Uint32(response[4:8]),
+		InternalPort: binary.BigEndian.Uint16(response[8:10]),
+		ExternalPort: binary.BigEndian.Uint16(response[10:12]),
+		Lifetime:     time.Duration(binary.BigEndian.Uint32(response[12:16])) * time.Second,
+	}, nil
+}
+
+// call sends a request and waits for the matching response, retransmitting with exponential
+// backoff until a response arrives, the attempts run out or ctx is done
+func (c *Client) call(ctx context.Context, request []byte, opcode byte, size int) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gateway: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Unblock reads when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	timeout := initialTimeout
+	for range c.maxAttempts {
+		if _, err := conn.Write(request); err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		response, err := readResponse(ctx, conn, opcode, size, time.Now().Add(timeout))
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			return response, nil
+		}
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			return nil, err
+		}
+		timeout *= 2
+	}
+
+	return nil, fmt.Errorf("no response from gateway %s", c.addr)
+}
+
+// readResponse reads packets until the response to opcode arrives or the deadline passes.
+// Unrelated packets (e.g. address change announcements) are skipped.
+func readResponse(ctx context.Context, conn net.Conn, opcode byte, size int, deadline time.Time) ([]byte, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	// A cancellation before the deadline was set would have been overwritten
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 16)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n < 4 || buf[0] != version || buf[1] != opcode+opResponseOffset {
+			continue
+		}
+
+		if code := binary.BigEndian.Uint16(buf[2:4]); code != 0 {
+			return nil, &ResultError{Code: code}
+		}
+		if n < size {
+			return nil, fmt.Errorf("NAT-PMP: short response (%d bytes)", n)
+		}
+		return buf[:n], nil
+	}
+}
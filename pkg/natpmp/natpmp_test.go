@@ -0,0 +1,117 @@
+package natpmp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// startResponder runs a NAT-PMP responder on a local UDP port. The handler returns the
+// response for a request, or nil to drop it.
+func startResponder(t *testing.T, handler func(request []byte) []byte) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := handler(buf[:n]); response != nil {
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func mappingResponse(request []byte, resultCode uint16, externalPort uint16) []byte {
+	response := make([]byte, 16)
+	response[1] = request[1] + opResponseOffset
+	binary.BigEndian.PutUint16(response[2:4], resultCode)
+	binary.BigEndian.PutUint32(response[4:8], 1234)
+	copy(response[8:10], request[4:6])
+	binary.BigEndian.PutUint16(response[10:12], externalPort)
+	copy(response[12:16], request[8:12])
+	return response
+}
+
+func TestAddPortMapping(t *testing.T) {
+	var dropped bool
+	addr := startResponder(t, func(request []byte) []byte {
+		// Drop the first request to exercise retransmission
+		if !dropped {
+			dropped = true
+			return nil
+		}
+		return mappingResponse(request, 0, 40000)
+	})
+
+	mapping, err := NewClient(addr).AddPortMapping(context.Background(), TCP, 1, 0, 60*time.Second)
+	if err != nil {
+		t.Fatalf("AddPortMapping failed: %v", err)
+	}
+
+	expected := Mapping{Protocol: TCP, InternalPort: 1, ExternalPort: 40000, Lifetime: 60 * time.Second, Epoch: 1234}
+	if *mapping != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *mapping)
+	}
+}
+
+func TestAddPortMappingResultError(t *testing.T) {
+	addr := startResponder(t, func(request []byte) []byte {
+		return mappingResponse(request, 2, 0)
+	})
+
+	_, err := NewClient(addr).AddPortMapping(context.Background(), UDP, 1, 0, time.Minute)
+
+	var resultErr *ResultError
+	if !errors.As(err, &resultErr) || resultErr.Code != 2 {
+		t.Fatalf("Expected result code 2, got %v", err)
+	}
+}
+
+func TestExternalAddress(t *testing.T) {
+	addr := startResponder(t, func(request []byte) []byte {
+		if request[1] != opExternalAddr {
+			return nil
+		}
+		return []byte{0, opResponseOffset, 0, 0, 0, 0, 0, 1, 203, 0, 113, 7}
+	})
+
+	address, err := NewClient(addr).ExternalAddress(context.Background())
+	if err != nil {
+		t.Fatalf("ExternalAddress failed: %v", err)
+	}
+	if address != netip.MustParseAddr("203.0.113.7") {
+		t.Errorf("Unexpected address %s", address)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	addr := startResponder(t, func([]byte) []byte { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewClient(addr).AddPortMapping(ctx, UDP, 1, 0, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Cancellation took %v", time.Since(start))
+	}
+}
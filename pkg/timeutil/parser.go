@@ -2,59 +2,187 @@ package timeutil
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Certificate duration limits enforced by the ProtonVPN API
+const (
+	minCertDuration = time.Minute
+	maxCertDays     = 365
+	maxCertDuration = maxCertDays * 24 * time.Hour
+)
+
+// isoDurationPattern matches ISO 8601 durations such as "P3M", "P1Y", "P2W" or "P1DT12H"
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
 // parseDaysDuration handles duration strings like "7d", "30d", "365d"
 func parseDaysDuration(durationStr string) (time.Duration, error) {
-	if !strings.HasSuffix(durationStr, "d") {
-		return 0, fmt.Errorf("not a day duration format")
+	return parseUnitDuration(durationStr, "d", 24*time.Hour)
+}
+
+// parseWeeksDuration handles duration strings like "2w"
+func parseWeeksDuration(durationStr string) (time.Duration, error) {
+	return parseUnitDuration(durationStr, "w", 7*24*time.Hour)
+}
+
+// parseUnitDuration handles an integer followed by a single unit suffix
+func parseUnitDuration(durationStr, suffix string, unit time.Duration) (time.Duration, error) {
+	if !strings.HasSuffix(durationStr, suffix) {
+		return 0, fmt.Errorf("not a %s duration format", suffix)
 	}
 
-	daysStr := strings.TrimSuffix(durationStr, "d")
-	days, err := strconv.Atoi(daysStr)
+	valueStr := strings.TrimSuffix(durationStr, suffix)
+	value, err := strconv.Atoi(valueStr)
 	if err != nil {
-		return 0, fmt.Errorf("invalid day value: %s", daysStr)
+		return 0, fmt.Errorf("invalid %s value: %s", suffix, valueStr)
 	}
 
-	return time.Duration(days) * 24 * time.Hour, nil
+	return time.Duration(value) * unit, nil
 }
 
-// ParseDuration parses a duration string that can be either Go duration or "Xd" format
+// parseISODuration parses an ISO 8601 duration. Years, months, weeks and days are calendar
+// units, so they are applied to the reference time (P1M from January 31 ends on March 3).
+func parseISODuration(durationStr string, from time.Time) (time.Duration, error) {
+	match := isoDurationPattern.FindStringSubmatch(strings.ToUpper(durationStr))
+	if match == nil || durationStr == "P" || strings.HasSuffix(strings.ToUpper(durationStr), "T") {
+		return 0, fmt.Errorf("invalid ISO 8601 duration: %s", durationStr)
+	}
+
+	values := make([]int, len(match)-1)
+	for i, group := range match[1:] {
+		if group == "" {
+			continue
+		}
+		value, err := strconv.Atoi(group)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration: %s", durationStr)
+		}
+		values[i] = value
+	}
+
+	years, months, weeks, days := values[0], values[1], values[2], values[3]
+	clock := time.Duration(values[4])*time.Hour + time.Duration(values[5])*time.Minute + time.Duration(values[6])*time.Second
+
+	until := from.AddDate(years, months, weeks*7+days).Add(clock)
+	return until.Sub(from), nil
+}
+
+// isOneYear reports whether an ISO 8601 duration is exactly one calendar year (P1Y or P12M)
+func isOneYear(durationStr string) bool {
+	match := isoDurationPattern.FindStringSubmatch(strings.ToUpper(durationStr))
+	if match == nil || strings.Join(match[3:], "") != "" {
+		return false
+	}
+	years, _ := strconv.Atoi(match[1])
+	months, _ := strconv.Atoi(match[2])
+	return years*12+months == 12
+}
+
+// ParseDuration parses a duration string: a Go duration ("1h30m"), days ("7d"), weeks ("2w")
+// or an ISO 8601 duration ("P3M", "P1Y", "PT12H"). Calendar units are counted from now.
 func ParseDuration(durationStr string) (time.Duration, error) {
-	// Try days format first
+	return ParseDurationFrom(durationStr, time.Now())
+}
+
+// ParseDurationFrom parses a duration string like ParseDuration, applying calendar units of
+// ISO 8601 durations to the given reference time
+func ParseDurationFrom(durationStr string, from time.Time) (time.Duration, error) {
+	if strings.HasPrefix(strings.ToUpper(durationStr), "P") {
+		return parseISODuration(durationStr, from)
+	}
+
+	// Try days and weeks formats first
 	if duration, err := parseDaysDuration(durationStr); err == nil {
 		return duration, nil
 	}
+	if duration, err := parseWeeksDuration(durationStr); err == nil {
+		return duration, nil
+	}
 
 	// Fall back to standard Go duration
 	return time.ParseDuration(durationStr)
 }
 
 // ParseToMinutes parses a duration string and converts it to minutes for the ProtonVPN API.
-// Accepts formats like "7d", "30d", "365d", "30m", "24h", "1h30m".
+// Accepts formats like "7d", "2w", "30m", "24h", "1h30m", "P3M", "P1Y".
 // Returns the duration in "XXX min" format as expected by the API.
 func ParseToMinutes(durationStr string) (string, error) {
-	duration, err := ParseDuration(durationStr)
+	return parseToMinutesFrom(durationStr, time.Now())
+}
+
+// parseToMinutesFrom is ParseToMinutes with calendar units counted from the given time
+func parseToMinutesFrom(durationStr string, from time.Time) (string, error) {
+	duration, err := ParseDurationFrom(durationStr, from)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration format: %s (expected e.g. 30m, 24h, 7d, 2w or P3M)", durationStr)
+	}
+
+	// A calendar year is 366 days when it spans February 29; P1Y still means the longest
+	// certificate the API allows
+	if duration > maxCertDuration && isOneYear(durationStr) {
+		duration = maxCertDuration
+	}
+
+	if duration < minCertDuration {
+		return "", fmt.Errorf("duration must be at least 1 minute (got %s)", duration)
+	}
+	if duration > maxCertDuration {
+		return "", fmt.Errorf("duration cannot exceed %d days (%s is %s)", maxCertDays, durationStr, formatDays(duration))
+	}
+
+	return formatMinutes(duration), nil
+}
+
+// ParseValidUntil parses an absolute expiry: an RFC 3339 timestamp or a date. A date means the
+// end of that day in local time, so "2026-12-31" is valid through December 31.
+func ParseValidUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
 	if err != nil {
-		return "", fmt.Errorf("invalid duration format: %s", durationStr)
+		return time.Time{}, fmt.Errorf("invalid date: %s (expected YYYY-MM-DD or an RFC 3339 timestamp)", value)
 	}
+	return date.AddDate(0, 0, 1), nil
+}
 
-	// Convert to minutes
-	minutes := int(duration.Minutes())
-	if minutes < 1 {
-		return "", fmt.Errorf("duration must be at least 1 minute")
+// ValidUntilToMinutes converts an absolute expiry into the API's minutes format
+func ValidUntilToMinutes(value string, now time.Time) (string, error) {
+	until, err := ParseValidUntil(value)
+	if err != nil {
+		return "", err
 	}
 
-	// Max 365 days = 525600 minutes
-	maxMinutes := 365 * 24 * 60
-	if minutes > maxMinutes {
-		return "", fmt.Errorf("duration cannot exceed 365 days")
+	duration := until.Sub(now)
+	switch {
+	case duration <= 0:
+		return "", fmt.Errorf("valid-until %s is in the past", value)
+	case duration < minCertDuration:
+		return "", fmt.Errorf("valid-until %s is less than 1 minute away", value)
+	case duration > maxCertDuration:
+		return "", fmt.Errorf("valid-until %s is %s away, but certificates cannot be valid for more than %d days", value, formatDays(duration), maxCertDays)
 	}
 
-	return fmt.Sprintf("%d min", minutes), nil
+	return formatMinutes(duration), nil
+}
+
+// formatMinutes formats a duration in the "XXX min" format expected by the API, rounding down
+// so the certificate never outlives the requested time
+func formatMinutes(duration time.Duration) string {
+	return fmt.Sprintf("%d min", int(duration.Minutes()))
+}
+
+// formatDays formats a duration as an exact number of days for error messages
+func formatDays(duration time.Duration) string {
+	day := 24 * time.Hour
+	if duration%day == 0 {
+		return pluralize(int(duration/day), "day", "days")
+	}
+	return fmt.Sprintf("%.2f days", duration.Hours()/24)
 }
 
 // ParseSessionDuration parses a session duration string for local caching.
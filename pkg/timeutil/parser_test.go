@@ -0,0 +1,101 @@
+package timeutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDurationFrom(t *testing.T) {
+	from := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"90m", 90 * time.Minute},
+		{"7d", 7 * day},
+		{"2w", 14 * day},
+		{"P1Y", 365 * day},
+		{"P3M", 90 * day}, // Calendar months: January 31 + 3 months normalizes to May 1
+		{"P2W", 14 * day},
+		{"P1DT12H", 36 * time.Hour},
+		{"PT30M", 30 * time.Minute},
+		{"p1d", day},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDurationFrom(tt.input, from)
+		if err != nil {
+			t.Errorf("ParseDurationFrom(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseDurationFrom(%q) = %v, expected %v", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"P", "PT", "P1YT", "P1H", "3M", "w", "xd"} {
+		if _, err := ParseDurationFrom(input, from); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseToMinutesLimits(t *testing.T) {
+	if got, err := ParseToMinutes("2w"); err != nil || got != "20160 min" {
+		t.Errorf("ParseToMinutes(2w) = %q, %v", got, err)
+	}
+
+	// The year from 2027-03-01 spans February 29, 2028 and has 366 days
+	leapYear := time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, input := range []string{"P1Y", "P12M", "365d"} {
+		if got, err := parseToMinutesFrom(input, leapYear); err != nil || got != "525600 min" {
+			t.Errorf("parseToMinutesFrom(%q) = %q, %v, expected 525600 min", input, got, err)
+		}
+	}
+	if _, err := parseToMinutesFrom("P1Y1D", leapYear); err == nil {
+		t.Error("Expected P1Y1D to exceed the limit")
+	}
+
+	tests := map[string]string{
+		"30s":  "at least 1 minute",
+		"366d": "cannot exceed 365 days",
+		"P2Y":  "cannot exceed 365 days",
+		"abc":  "invalid duration format",
+	}
+	for input, message := range tests {
+		if _, err := ParseToMinutes(input); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("ParseToMinutes(%q) error = %v, expected %q", input, err, message)
+		}
+	}
+}
+
+func TestValidUntilToMinutes(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	if got, err := ValidUntilToMinutes("2026-10-19T12:00:00Z", now); err != nil || got != "1440 min" {
+		t.Errorf("RFC 3339 timestamp: got %q, %v", got, err)
+	}
+
+	// A date is valid through the end of that day in local time
+	date := time.Date(2026, 12, 31, 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	expected := formatMinutes(date.Sub(now))
+	if got, err := ValidUntilToMinutes("2026-12-31", now); err != nil || got != expected {
+		t.Errorf("Date: got %q, %v, expected %q", got, err, expected)
+	}
+
+	tests := map[string]string{
+		"2026-10-18T11:00:00Z":      "in the past",
+		"2026-10-18T12:00:30Z":      "less than 1 minute away",
+		"2028-01-01T00:00:00Z":      "cannot be valid for more than 365 days",
+		"end of quarter":            "invalid date",
+		"2026-10-18T12:00:00+99:00": "invalid date",
+	}
+	for input, message := range tests {
+		if _, err := ValidUntilToMinutes(input, now); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("ValidUntilToMinutes(%q) error = %v, expected %q", input, err, message)
+		}
+	}
+}
@@ -4,7 +4,6 @@ package wireguard
 import (
 	"bytes"
 	"fmt"
-	"os"
 	"strings"
 	"text/template"
 	"time"
@@ -12,14 +11,26 @@ import (
 	"protonvpn-wg-config-generate/internal/api"
 	"protonvpn-wg-config-generate/internal/config"
 	"protonvpn-wg-config-generate/internal/constants"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/pkg/fileutil"
 )
 
+// PrivateKeyPlaceholder replaces the private key in configs for keys generated on the device
+const PrivateKeyPlaceholder = "REPLACE_WITH_DEVICE_PRIVATE_KEY"
+
+// DryRunPrivateKeyPlaceholder replaces the private key in configs shown by a dry run, which
+// doesn't generate a key
+const DryRunPrivateKeyPlaceholder = "GENERATED_WHEN_NOT_A_DRY_RUN"
+
 // wireguardConfigTemplate is the template for generating WireGuard configuration
-const wireguardConfigTemplate = `[Interface]
+const wireguardConfigTemplate = `{{if .PeerOnly}}# Interface settings for the device:
+# {{.AddressLine}}
+# DNS = {{.DNS}}
+{{else}}[Interface]
 PrivateKey = {{.PrivateKey}}
 {{.AddressLine}}
 DNS = {{.DNS}}
-
+{{end}}
 [Peer]
 PublicKey = {{.PublicKey}}
 AllowedIPs = {{.AllowedIPs}}
@@ -28,6 +39,7 @@ Endpoint = {{.Endpoint}}:{{.Port}}
 
 // configData holds the data for the WireGuard config template
 type configData struct {
+	PeerOnly    bool
 	PrivateKey  string
 	AddressLine string
 	DNS         string
@@ -39,8 +51,9 @@ type configData struct {
 
 // ConfigGenerator generates WireGuard configuration files
 type ConfigGenerator struct {
-	config   *config.Config
-	template *template.Template
+	config      *config.Config
+	template    *template.Template
+	certificate *api.VPNInfo
 }
 
 // NewConfigGenerator creates a new configuration generator
@@ -52,6 +65,12 @@ func NewConfigGenerator(cfg *config.Config) *ConfigGenerator {
 	}
 }
 
+// SetCertificate records the certificate issued for the config, whose features are
+// written to the metadata header
+func (g *ConfigGenerator) SetCertificate(certificate *api.VPNInfo) {
+	g.certificate = certificate
+}
+
 // Generate creates a WireGuard configuration file
 func (g *ConfigGenerator) Generate(server *api.LogicalServer, physicalServer *api.PhysicalServer, privateKey string) error {
 	content, err := g.buildConfig(server, physicalServer, privateKey)
@@ -59,18 +78,29 @@ func (g *ConfigGenerator) Generate(server *api.LogicalServer, physicalServer *ap
 		return err
 	}
 
-	if err := os.WriteFile(g.config.OutputFile, []byte(content), 0o600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	if err := fileutil.WriteFileAtomic(g.config.OutputFile, []byte(content), 0o600); err != nil {
+		return errclass.Wrap(errclass.IO, fmt.Errorf("failed to write config file: %w", err))
 	}
 
 	return nil
 }
 
+// Render returns the configuration Generate would write, without writing it
+func (g *ConfigGenerator) Render(server *api.LogicalServer, physicalServer *api.PhysicalServer, privateKey string) (string, error) {
+	return g.buildConfig(server, physicalServer, privateKey)
+}
+
 func (g *ConfigGenerator) buildConfig(server *api.LogicalServer, physicalServer *api.PhysicalServer, privateKey string) (string, error) {
 	// Build metadata header
 	metadata := g.buildMetadata(server, physicalServer)
 
+	// The private key of externally generated keys stays on the device
+	if privateKey == "" {
+		privateKey = PrivateKeyPlaceholder
+	}
+
 	data := configData{
+		PeerOnly:    g.config.PeerOnly,
 		PrivateKey:  privateKey,
 		AddressLine: g.buildAddressLine(),
 		DNS:         strings.Join(g.config.DNSServers, ", "),
@@ -99,10 +129,13 @@ func (g *ConfigGenerator) buildMetadata(server *api.LogicalServer, physicalServe
 	var metadata strings.Builder
 
 	metadata.WriteString("# ProtonVPN WireGuard Configuration\n")
-	metadata.WriteString(fmt.Sprintf("# Generated: %s\n", time.Now().Format("2006-01-02 15:04:05 MST")))
+	metadata.WriteString(fmt.Sprintf("# Generated: %s\n", time.Now().Format(generatedTimeFormat)))
 	if g.config.DeviceName != "" {
 		metadata.WriteString(fmt.Sprintf("# Device: %s\n", g.config.DeviceName))
 	}
+	if g.certificate != nil {
+		metadata.WriteString(certificateHeader(g.certificate))
+	}
 	metadata.WriteString("#\n")
 	metadata.WriteString("# Server Information:\n")
 	metadata.WriteString(fmt.Sprintf("# - Name: %s\n", server.Name))
@@ -117,3 +117,29 @@ func TestConfigGenerationWithIPv6(t *testing.T) {
 		t.Errorf("Expected both IPv4 and IPv6 in AllowedIPs, got:\n%s", result)
 	}
 }
+
+func TestConfigGenerationExternalKey(t *testing.T) {
+	server := &api.LogicalServer{Name: "Test-Server"}
+	physicalServer := &api.PhysicalServer{EntryIP: "192.168.1.1", X25519PublicKey: "testPublicKey123="}
+
+	cfg := &config.Config{DNSServers: []string{"10.2.0.1"}, AllowedIPs: []string{"0.0.0.0/0"}}
+	result, err := NewConfigGenerator(cfg).buildConfig(server, physicalServer, "")
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if !strings.Contains(result, "PrivateKey = "+PrivateKeyPlaceholder) {
+		t.Errorf("Expected private key placeholder, got:\n%s", result)
+	}
+
+	cfg.PeerOnly = true
+	result, err = NewConfigGenerator(cfg).buildConfig(server, physicalServer, "")
+	if err != nil {
+		t.Fatalf("buildConfig failed: %v", err)
+	}
+	if strings.Contains(result, "[Interface]") || strings.Contains(result, "PrivateKey") {
+		t.Errorf("Expected only the peer section, got:\n%s", result)
+	}
+	if !strings.Contains(result, "# Address = 10.2.0.2/32") || !strings.Contains(result, "[Peer]\nPublicKey = testPublicKey123=") {
+		t.Errorf("Expected commented interface settings and peer section, got:\n%s", result)
+	}
+}
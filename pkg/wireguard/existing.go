@@ -0,0 +1,227 @@
+package wireguard
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/errclass"
+	"protonvpn-wg-config-generate/pkg/fileutil"
+)
+
+// generatedTimeFormat is the format of the "Generated" metadata line
+const generatedTimeFormat = "2006-01-02 15:04:05 MST"
+
+// ExistingConfig is a previously generated WireGuard configuration file
+type ExistingConfig struct {
+	Path           string
+	Content        string
+	PrivateKey     string // Empty for peer-only configs
+	PeerOnly       bool
+	DeviceName     string
+	Endpoint       string
+	ServerFeatures []string
+	ExpiresAt      time.Time // Zero for configs generated before expiry was recorded
+	RefreshAt      time.Time
+}
+
+// ReadConfig reads a generated configuration file and extracts the values needed to renew it.
+// Peer-only configs have no private key.
+func ReadConfig(path string) (*ExistingConfig, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // reading the user-specified config file is intended
+	if err != nil {
+		return nil, errclass.Wrap(errclass.IO, fmt.Errorf("failed to read config file: %w", err))
+	}
+
+	existing, err := ParseConfig(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+	existing.Path = path
+	return existing, nil
+}
+
+// ParseConfig extracts the private key, device name, expiry and endpoint from configuration
+// content. A peer-only config, with a [Peer] section but no [Interface] section, has no private
+// key.
+func ParseConfig(content string) (*ExistingConfig, error) {
+	existing := parseHeader(content)
+	if existing.PrivateKey == "" && !existing.PeerOnly {
+		return nil, errclass.New(errclass.InvalidInput, "no PrivateKey found")
+	}
+	return existing, nil
+}
+
+// parseHeader extracts the metadata, private key and endpoint from configuration content
+// without requiring any of them
+func parseHeader(content string) *ExistingConfig {
+	existing := &ExistingConfig{Content: content}
+	hasInterface, hasPeer := false, false
+	for _, line := range strings.Split(existing.Content, "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := headerValue(line, "Device"); ok {
+			existing.DeviceName = value
+			continue
+		}
+		if value, ok := headerValue(line, "- Features"); ok {
+			existing.ServerFeatures = strings.Split(value, ", ")
+			continue
+		}
+		if value, ok := headerValue(line, "Certificate Expires"); ok {
+			existing.ExpiresAt, _ = time.Parse(time.RFC3339, value)
+			continue
+		}
+		if value, ok := headerValue(line, "Certificate Refresh"); ok {
+			existing.RefreshAt, _ = time.Parse(time.RFC3339, value)
+			continue
+		}
+		switch line {
+		case "[Interface]":
+			hasInterface = true
+			continue
+		case "[Peer]":
+			hasPeer = true
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "PrivateKey":
+			existing.PrivateKey = strings.TrimSpace(value)
+		case "Endpoint":
+			existing.Endpoint = strings.TrimSpace(value)
+		}
+	}
+
+	existing.PeerOnly = hasPeer && !hasInterface
+	return existing
+}
+
+// ReadKeyFile reads a WireGuard private key from a file containing either the bare base64 key
+// (as written by `wg genkey`) or a complete WireGuard configuration
+func ReadKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // reading the user-specified key file is intended
+	if err != nil {
+		return "", errclass.Wrap(errclass.IO, fmt.Errorf("failed to read key file: %w", err))
+	}
+
+	privateKey, err := ParseKey(string(data))
+	if err != nil {
+		return "", fmt.Errorf("%w in %s", err, path)
+	}
+	return privateKey, nil
+}
+
+// ParseKey extracts a private key from either a bare base64 key or a complete configuration
+func ParseKey(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if !strings.Contains(content, "\n") && !strings.Contains(content, "PrivateKey") {
+		return content, nil
+	}
+
+	existing := parseHeader(content)
+	if existing.PrivateKey == "" {
+		return "", errclass.New(errclass.InvalidInput, "no PrivateKey found")
+	}
+	return existing.PrivateKey, nil
+}
+
+// HasPrivateKey reports whether the config holds a usable private key, rather than none
+// (peer-only configs) or the placeholder for a key generated on the device
+func (e *ExistingConfig) HasPrivateKey() bool {
+	return e.PrivateKey != "" && e.PrivateKey != PrivateKeyPlaceholder
+}
+
+// HasServerFeature reports whether the server the config was generated for has a feature
+// (as named by api.GetFeatureNames)
+func (e *ExistingConfig) HasServerFeature(name string) bool {
+	for _, feature := range e.ServerFeatures {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Renew rewrites the metadata that changes when the certificate is renewed (generation time,
+// device name, certificate features and expiry) and leaves the rest of the file, including the
+// key and endpoint, untouched
+func (e *ExistingConfig) Renew(certificate *api.VPNInfo, generated time.Time) error {
+	content := setHeaderValue(e.Content, "Generated", generated.Format(generatedTimeFormat))
+	if certificate.DeviceName != "" {
+		content = setHeaderValue(content, "Device", certificate.DeviceName)
+	}
+	content = setHeaderValue(content, "Certificate Features", certificate.Features.String())
+	if certificate.ExpirationTime > 0 {
+		content = setHeaderValue(content, "Certificate Expires", formatUnix(certificate.ExpirationTime))
+	}
+	if certificate.RefreshTime > 0 {
+		content = setHeaderValue(content, "Certificate Refresh", formatUnix(certificate.RefreshTime))
+	}
+
+	if content == e.Content {
+		return nil
+	}
+	if err := fileutil.WriteFileAtomic(e.Path, []byte(content), 0o600); err != nil {
+		return errclass.Wrap(errclass.IO, fmt.Errorf("failed to write config file: %w", err))
+	}
+
+	updated, err := ParseConfig(content)
+	if err != nil {
+		return err
+	}
+	updated.Path = e.Path
+	*e = *updated
+	return nil
+}
+
+// certificateHeader returns the metadata lines describing a certificate
+func certificateHeader(certificate *api.VPNInfo) string {
+	header := fmt.Sprintf("# Certificate Features: %s\n", certificate.Features)
+	if certificate.ExpirationTime > 0 {
+		header += fmt.Sprintf("# Certificate Expires: %s\n", formatUnix(certificate.ExpirationTime))
+	}
+	if certificate.RefreshTime > 0 {
+		header += fmt.Sprintf("# Certificate Refresh: %s\n", formatUnix(certificate.RefreshTime))
+	}
+	return header
+}
+
+// formatUnix formats a unix timestamp from the API in a machine-readable way
+func formatUnix(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// headerValue returns the value of a "# Key: value" metadata line
+func headerValue(line, key string) (string, bool) {
+	value, ok := strings.CutPrefix(line, "# "+key+": ")
+	return strings.TrimSpace(value), ok
+}
+
+// setHeaderValue replaces a "# Key: value" metadata line, appending it to the first header
+// block (the one with the "Generated" line) if the file doesn't have it yet
+func setHeaderValue(content, key, value string) string {
+	lines := strings.Split(content, "\n")
+	newLine := fmt.Sprintf("# %s: %s", key, value)
+
+	insertAt := -1
+	for i, line := range lines {
+		if _, ok := headerValue(line, key); ok {
+			lines[i] = newLine
+			return strings.Join(lines, "\n")
+		}
+		if strings.TrimSpace(line) == "#" && insertAt < 0 {
+			insertAt = i
+		}
+	}
+
+	if insertAt < 0 {
+		return content
+	}
+	lines = append(lines[:insertAt], append([]string{newLine}, lines[insertAt:]...)...)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,109 @@
+package wireguard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"protonvpn-wg-config-generate/internal/api"
+	"protonvpn-wg-config-generate/internal/config"
+)
+
+func TestRenewKeepsKeyAndEndpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.conf")
+	cfg := &config.Config{
+		DNSServers: []string{"10.2.0.1"},
+		AllowedIPs: []string{"0.0.0.0/0"},
+		OutputFile: path,
+	}
+
+	server := &api.LogicalServer{Name: "Test-Server"}
+	physicalServer := &api.PhysicalServer{EntryIP: "192.168.1.1", X25519PublicKey: "testPublicKey123="}
+	if err := NewConfigGenerator(cfg).Generate(server, physicalServer, "testPrivateKey456="); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	existing, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("ReadConfig failed: %v", err)
+	}
+	if existing.PrivateKey != "testPrivateKey456=" || existing.Endpoint != "192.168.1.1:51820" {
+		t.Fatalf("Unexpected parsed config: %+v", existing)
+	}
+
+	original := existing.Content
+	certificate := &api.VPNInfo{
+		DeviceName: "WireGuard-user-1700000000",
+		Features:   api.CertificateFeatures{NetshieldLevel: 2, PortForwarding: true},
+		// 2031-01-01T00:00:00Z
+		ExpirationTime: 1924992000,
+	}
+	if err := existing.Renew(certificate, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatalf("Renew failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file in a temporary directory
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	renewed := string(data)
+
+	expectedHeader := "# Generated: 2030-01-02 03:04:05 UTC\n" +
+		"# Device: WireGuard-user-1700000000\n" +
+		"# Certificate Features: NetShield:2 Accelerator:off ModerateNAT:off PortForwarding:on\n" +
+		"# Certificate Expires: 2031-01-01T00:00:00Z\n#\n"
+	if !strings.Contains(renewed, expectedHeader) {
+		t.Errorf("Expected updated header, got:\n%s", renewed)
+	}
+	if !existing.ExpiresAt.Equal(time.Unix(1924992000, 0)) {
+		t.Errorf("Expected expiry to be parsed back, got %v", existing.ExpiresAt)
+	}
+
+	// Everything from the interface section on must be unchanged
+	section := original[strings.Index(original, "[Interface]"):]
+	if !strings.HasSuffix(renewed, section) {
+		t.Errorf("Expected interface and peer sections to be unchanged, got:\n%s", renewed)
+	}
+}
+
+func TestReadPeerOnlyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peer.conf")
+	cfg := &config.Config{
+		DNSServers: []string{"10.2.0.1"},
+		AllowedIPs: []string{"0.0.0.0/0"},
+		OutputFile: path,
+		DeviceName: "my-router",
+		PeerOnly:   true,
+	}
+
+	server := &api.LogicalServer{Name: "Test-Server"}
+	physicalServer := &api.PhysicalServer{EntryIP: "192.168.1.1", X25519PublicKey: "testPublicKey123="}
+	generator := NewConfigGenerator(cfg)
+	generator.SetCertificate(&api.VPNInfo{DeviceName: "my-router", ExpirationTime: 1924992000, RefreshTime: 1924000000})
+	if err := generator.Generate(server, physicalServer, ""); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	existing, err := ReadConfig(path)
+	if err != nil {
+		t.Fatalf("ReadConfig failed: %v", err)
+	}
+	if !existing.PeerOnly || existing.HasPrivateKey() {
+		t.Errorf("Expected a peer-only config without a private key, got %+v", existing)
+	}
+	if existing.DeviceName != "my-router" || existing.Endpoint != "192.168.1.1:51820" {
+		t.Errorf("Unexpected parsed config: %+v", existing)
+	}
+	if !existing.ExpiresAt.Equal(time.Unix(1924992000, 0)) || !existing.RefreshAt.Equal(time.Unix(1924000000, 0)) {
+		t.Errorf("Expected expiry and refresh times to be parsed, got %v and %v", existing.ExpiresAt, existing.RefreshAt)
+	}
+
+	if _, err := ParseKey(existing.Content); err == nil {
+		t.Error("Expected ParseKey to fail for a peer-only config")
+	}
+	if _, err := ParseConfig(strings.Replace(existing.Content, "# Interface settings for the device:", "[Interface]", 1)); err == nil {
+		t.Error("Expected an interface section without a private key to be rejected")
+	}
+}